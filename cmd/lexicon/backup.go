@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"lexicon/internal/backup"
+	"lexicon/internal/config"
+)
+
+func runBackup(args []string) error {
+	fs := flags("backup")
+	keep := fs.Int("keep", 0, "number of backups to keep (default: the backup_keep setting)")
+	list := fs.Bool("list", false, "list backups instead of taking one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadForCommand()
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		files, err := backup.List(cfg.BackupDir())
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			fmt.Printf("%s  %s  %s\n", f.Name, f.CreatedAt.Local().Format("2006-01-02 15:04:05"), f.HumanSize())
+		}
+		return nil
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *keep <= 0 {
+		if *keep, err = db.BackupKeep(); err != nil {
+			return err
+		}
+	}
+
+	f, err := backup.Create(db, cfg.BackupDir(), *keep)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up to %s (%s)\n", f.Path, f.HumanSize())
+	return nil
+}
+
+func runRestore(args []string) error {
+	fs := flags("restore")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a backup file")
+	}
+
+	cfg, err := config.LoadForCommand()
+	if err != nil {
+		return err
+	}
+
+	// Accept a path, or the name of a backup in the backup directory
+	src := fs.Arg(0)
+	if _, err := os.Stat(src); err != nil {
+		f, err := backup.Get(cfg.BackupDir(), filepath.Base(src))
+		if err != nil {
+			return fmt.Errorf("%s: no such file or backup", src)
+		}
+		src = f.Path
+	}
+
+	if err := backup.Restore(src, cfg.DatabasePath()); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s from %s.\nThe previous database was kept as %s.before-restore.\n", cfg.DatabasePath(), src, cfg.DatabasePath())
+	return nil
+}
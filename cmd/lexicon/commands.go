@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"lexicon"
+	"lexicon/internal/archive"
+	"lexicon/internal/book"
+	"lexicon/internal/database"
+	"lexicon/internal/markdown"
+	"lexicon/internal/server"
+	"lexicon/internal/site"
+)
+
+func runCreateAdmin(args []string) error {
+	fs := flags("create-admin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// LEXICON_ADMIN_USERNAME and LEXICON_ADMIN_PASSWORD skip the prompts
+	username, password := os.Getenv("LEXICON_ADMIN_USERNAME"), os.Getenv("LEXICON_ADMIN_PASSWORD")
+	if username == "" {
+		if username, err = prompt("Admin username: "); err != nil {
+			return err
+		}
+	}
+	if password == "" {
+		if password, err = promptNewPassword("Admin password: "); err != nil {
+			return err
+		}
+	}
+
+	if err := createAdmin(db, username, password); err != nil {
+		return err
+	}
+	fmt.Printf("Admin user %s created.\n", username)
+	return nil
+}
+
+func runResetPassword(args []string) error {
+	fs := flags("reset-password")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a username")
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	user, err := db.GetUserByUsername(fs.Arg(0))
+	if err == database.ErrNotFound {
+		return fmt.Errorf("no user named %s", fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	password, err := promptNewPassword("New password: ")
+	if err != nil {
+		return err
+	}
+	if err := db.SetPassword(user.ID, password); err != nil {
+		return err
+	}
+
+	// Sign out everywhere, in case the old password leaked
+	if err := db.DeleteUserSessions(user.ID); err != nil {
+		return err
+	}
+
+	fmt.Printf("Password for %s changed.\n", user.Username)
+	return nil
+}
+
+func runSetSetting(args []string) error {
+	fs := flags("set-setting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("expected a key and a value")
+	}
+	key, value := fs.Arg(0), fs.Arg(1)
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	settings, err := db.GetAllSettings()
+	if err != nil {
+		return err
+	}
+	if _, ok := settings[key]; !ok {
+		return fmt.Errorf("unknown setting %s", key)
+	}
+
+	if err := db.SetSetting(key, value); err != nil {
+		return err
+	}
+	fmt.Printf("%s = %s\n", key, value)
+	return nil
+}
+
+func runListUsers(args []string) error {
+	if err := flags("list-users").Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	users, err := db.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSERNAME\tROLE\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", u.ID, u.Username, u.Role, u.CreatedAt.Format("2006-01-02"))
+	}
+	return tw.Flush()
+}
+
+func runExport(args []string) error {
+	fs := flags("export")
+	out := fs.String("o", "", "output file, or - for standard output (default lexicon-export.zip or lexicon-history.jsonl)")
+	history := fs.Bool("history", false, "export the full history as JSON Lines instead of the latest pages as a ZIP")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = "lexicon-export.zip"
+		if *history {
+			*out = "lexicon-history.jsonl"
+		}
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *history {
+		err = archive.ExportHistory(context.Background(), db, w)
+	} else {
+		err = archive.Export(db, w)
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "Exported to %s\n", *out)
+	}
+	return nil
+}
+
+func runSite(args []string) error {
+	fs := flags("site")
+	out := fs.String("o", "lexicon-site.zip", "output ZIP file, or - for standard output")
+	dir := fs.String("dir", "", "write the site into this directory instead of a ZIP")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Customized templates and assets in the working directory carry over,
+	// as they do for the server
+	templates, static := server.Assets(lexicon.EmbeddedFS)
+	gen := &site.Generator{DB: db, Templates: templates, Static: static}
+
+	if *dir != "" {
+		if err := gen.WriteDir(*dir); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Site written to %s\n", *dir)
+		return nil
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := gen.WriteZip(w); err != nil {
+		return err
+	}
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "Site exported to %s\n", *out)
+	}
+	return nil
+}
+
+func runBook(args []string) error {
+	fs := flags("book")
+	format := fs.String("format", "epub", "epub, or html for a single self-contained page")
+	orderName := fs.String("order", "title", "arrange entries by title or by round")
+	out := fs.String("o", "", "output file, or - for standard output (default lexicon.epub or lexicon.html)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "epub" && *format != "html" {
+		return fmt.Errorf("unknown format %q (want epub or html)", *format)
+	}
+	order, err := book.ParseOrder(*orderName)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = "lexicon." + *format
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	b, err := book.Build(db, order)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	templates, _ := server.Assets(lexicon.EmbeddedFS)
+	if *format == "epub" {
+		err = b.WriteEPUB(w, templates)
+	} else {
+		err = b.WriteHTML(w, templates)
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "Book exported to %s\n", *out)
+	}
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := flags("import")
+	as := fs.String("as", "", "user credited with pages whose author doesn't exist here (default: the first admin)")
+	policy := fs.String("policy", "skip", "what to do with pages that already exist: skip, new-revision or overwrite")
+	dryRun := fs.Bool("dry-run", false, "report what would be imported without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected an export ZIP")
+	}
+
+	conflicts, err := archive.ParsePolicy(*policy)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	a, err := archive.ReadZip(f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fallback, err := importUser(db, *as)
+	if err != nil {
+		return err
+	}
+
+	report, err := archive.Import(db, a, archive.Options{Fallback: fallback, Policy: conflicts, DryRun: *dryRun})
+	if report != nil {
+		printReport(report)
+	}
+	return err
+}
+
+func printReport(report *archive.Report) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, a := range report.Actions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.Result, a.Slug, a.Author, a.Note)
+	}
+	tw.Flush()
+
+	if len(report.UnknownAuthors) > 0 {
+		fmt.Printf("\nCredited to the fallback user: %s\n", strings.Join(report.UnknownAuthors, ", "))
+	}
+
+	fmt.Println()
+	if report.DryRun {
+		fmt.Print("Dry run, nothing changed. Would have: ")
+	}
+	fmt.Printf("%d created, %d revised, %d overwritten, %d skipped, %d phantoms, %d failed\n",
+		report.Count(archive.Created), report.Count(archive.Revised), report.Count(archive.Overwritten),
+		report.Count(archive.Skipped), report.Count(archive.Phantom), report.Count(archive.Failed))
+}
+
+// importUser returns the named user, or the first admin if name is empty.
+func importUser(db *database.DB, name string) (*database.User, error) {
+	if name != "" {
+		user, err := db.GetUserByUsername(name)
+		if err == database.ErrNotFound {
+			return nil, fmt.Errorf("no user named %s", name)
+		}
+		return user, err
+	}
+
+	users, err := db.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if u.IsAdmin() {
+			return u, nil
+		}
+	}
+	return nil, errors.New("no admin user exists; run create-admin first or pass -as")
+}
+
+func runReindex(args []string) error {
+	if err := flags("reindex").Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	n, err := db.RebuildSearchIndex()
+	if err != nil {
+		return fmt.Errorf("failed to rebuild search index: %w", err)
+	}
+	fmt.Printf("Indexed %d pages for search.\n", n)
+
+	md := markdown.New(nil)
+	err = db.RebuildLinks(func(content string) []database.Link {
+		return markdown.DatabaseLinks(md.ExtractLinks(content))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rebuild link graph: %w", err)
+	}
+	retired, err := db.RetireOrphanedPhantoms()
+	if err != nil {
+		return fmt.Errorf("failed to retire orphaned phantoms: %w", err)
+	}
+	fmt.Printf("Rebuilt link graph; retired %d phantoms that are no longer cited.\n", retired)
+	return nil
+}
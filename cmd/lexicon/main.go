@@ -0,0 +1,138 @@
+// Command lexicon runs the wiki server and its admin commands.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"lexicon"
+	"lexicon/internal/config"
+	"lexicon/internal/database"
+	"lexicon/internal/server"
+)
+
+// command is a subcommand of the lexicon binary.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "", "Run the wiki server (the default)", runServe},
+		{"create-admin", "", "Create an admin account", runCreateAdmin},
+		{"reset-password", "USERNAME", "Set a new password for a user", runResetPassword},
+		{"set-setting", "KEY VALUE", "Change a wiki setting", runSetSetting},
+		{"list-users", "", "List user accounts", runListUsers},
+		{"export", "[-history] [-o FILE]", "Export pages as a ZIP of markdown files, or the full history", runExport},
+		{"site", "[-o FILE | -dir DIR]", "Generate a static website as a ZIP or into a directory", runSite},
+		{"book", "[-format epub|html] [-order title|round] [-o FILE]", "Export the wiki as an EPUB or a single HTML file", runBook},
+		{"import", "[-as USERNAME] [-policy P] [-dry-run] FILE", "Import pages from an export ZIP", runImport},
+		{"reindex", "", "Rebuild the search index and link graph", runReindex},
+		{"backup", "[-keep N] [-list]", "Snapshot the database into the backup directory", runBackup},
+		{"restore", "FILE", "Replace the database with a backup (stop the server first)", runRestore},
+	}
+}
+
+func main() {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			err := cmd.run(args)
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "lexicon %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "lexicon: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: lexicon <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	tw := tabwriter.NewWriter(os.Stderr, 0, 0, 3, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "All commands read the LEXICON_* environment variables and operate on")
+	fmt.Fprintln(os.Stderr, "the database in LEXICON_DATA_DIR.")
+}
+
+// flags returns a flag set for a command that prints the command's usage.
+func flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		for _, cmd := range commands {
+			if cmd.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: lexicon %s %s\n\n%s.\n", cmd.name, cmd.args, cmd.summary)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// openDB opens the database for an admin command.
+func openDB() (*database.DB, error) {
+	cfg, err := config.LoadForCommand()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return database.Open(cfg.DatabasePath())
+}
+
+func runServe(args []string) error {
+	if err := flags("serve").Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := database.Open(cfg.DatabasePath())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := firstRunSetup(cfg, db); err != nil {
+		return err
+	}
+
+	return server.New(cfg, db, lexicon.EmbeddedFS).Run()
+}
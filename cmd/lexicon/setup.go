@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"lexicon/internal/config"
+	"lexicon/internal/database"
+
+	"golang.org/x/term"
+)
+
+// usernameRegex matches the usernames registration accepts.
+var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]{3,50}$`)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// firstRunSetup creates the first admin account if there is none, from the
+// environment or by asking on the terminal.
+func firstRunSetup(cfg *config.Config, db *database.DB) error {
+	needsSetup, err := db.NeedsAdminSetup()
+	if err != nil {
+		return err
+	}
+	if !needsSetup {
+		return nil
+	}
+
+	if cfg.AdminUsername != "" && cfg.AdminPassword != "" {
+		if err := createAdmin(db, cfg.AdminUsername, cfg.AdminPassword); err != nil {
+			return err
+		}
+		fmt.Printf("Admin user %s created.\n", cfg.AdminUsername)
+		return nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("no admin user exists: set LEXICON_ADMIN_USERNAME and LEXICON_ADMIN_PASSWORD, " +
+			"run interactively, or run `lexicon create-admin`")
+	}
+
+	fmt.Println("Lexicon Wiki - First Time Setup")
+	fmt.Println("================================")
+	fmt.Println("No admin user found. Please create one.")
+	fmt.Println()
+
+	username, password, err := promptCredentials()
+	if err != nil {
+		return err
+	}
+	if err := createAdmin(db, username, password); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Admin user created. Starting server...")
+	return nil
+}
+
+// promptCredentials asks for a new admin's username and password.
+func promptCredentials() (username, password string, err error) {
+	for {
+		username, err = prompt("Admin username: ")
+		if err != nil {
+			return "", "", err
+		}
+		if usernameRegex.MatchString(username) {
+			break
+		}
+		fmt.Println("Username must be 3-50 characters: letters, numbers, underscores.")
+	}
+
+	password, err = promptNewPassword("Admin password: ")
+	return username, password, err
+}
+
+// promptNewPassword asks for a password twice until both match and it is
+// long enough. Without a terminal it reads a single line instead, so
+// passwords can be piped in.
+func promptNewPassword(label string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		password, err := prompt("")
+		if err != nil {
+			return "", err
+		}
+		return password, validatePassword(password)
+	}
+
+	for {
+		password, err := promptHidden(label)
+		if err != nil {
+			return "", err
+		}
+		if err := validatePassword(password); err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		confirm, err := promptHidden("Confirm password: ")
+		if err != nil {
+			return "", err
+		}
+		if confirm != password {
+			fmt.Println("Passwords don't match.")
+			continue
+		}
+		return password, nil
+	}
+}
+
+func validatePassword(password string) error {
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	return nil
+}
+
+func prompt(label string) (string, error) {
+	fmt.Print(label)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func promptHidden(label string) (string, error) {
+	fmt.Print(label)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	return string(password), err
+}
+
+func createAdmin(db *database.DB, username, password string) error {
+	if !usernameRegex.MatchString(username) {
+		return errors.New("username must be 3-50 characters: letters, numbers, underscores")
+	}
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	if _, err := db.GetUserByUsername(username); err == nil {
+		return fmt.Errorf("user %s already exists", username)
+	}
+	_, err := db.CreateUser(username, password, "admin")
+	return err
+}
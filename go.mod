@@ -6,6 +6,7 @@ require (
 	github.com/go-chi/chi/v5 v5.1.0
 	github.com/yuin/goldmark v1.7.8
 	golang.org/x/crypto v0.29.0
+	golang.org/x/term v0.26.0
 	golang.org/x/text v0.20.0
 	modernc.org/sqlite v1.34.1
 )
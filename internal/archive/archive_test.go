@@ -0,0 +1,213 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lexicon/internal/database"
+)
+
+func openTestDB(t *testing.T, name string) *database.DB {
+	t.Helper()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := openTestDB(t, "src.db")
+	alice, err := src.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "Elves cite [[Dwarves]].\n\n---\n\nA rule, not front matter."
+	links := []database.Link{{TargetSlug: "dwarves", DisplayText: "Dwarves"}}
+	elves, err := src.CreatePage("elves", "Elves: a study", content, alice.ID, links, database.RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.CreatePhantom("dwarves", "Dwarves", alice.ID, elves.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Export(src, &buf); err != nil {
+		t.Fatal(err)
+	}
+	a, err := ReadZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Entries) != 1 || len(a.Metadata.Phantoms) != 1 {
+		t.Fatalf("read %d entries and %d phantoms, want 1 each", len(a.Entries), len(a.Metadata.Phantoms))
+	}
+	if e := a.Entries[0]; e.Slug != "elves" || e.Title != "Elves: a study" || e.Author != "alice" || e.Content != content {
+		t.Errorf("entry = %+v", e)
+	}
+
+	dst := openTestDB(t, "dst.db")
+	admin, err := dst.CreateUser("admin", "password123", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstAlice, err := dst.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run changes nothing
+	report, err := Import(dst, a, Options{Fallback: admin, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(Created) != 1 || report.Count(Phantom) != 1 {
+		t.Errorf("dry run report = %+v, want 1 created and 1 phantom", report.Actions)
+	}
+	if exists, _ := dst.PageExists("elves"); exists {
+		t.Error("dry run created a page")
+	}
+
+	if _, err := Import(dst, a, Options{Fallback: admin}); err != nil {
+		t.Fatal(err)
+	}
+	page := mustPage(t, dst, "elves")
+	rev, err := dst.GetCurrentRevision(page.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.Content != content || rev.AuthorID != dstAlice.ID {
+		t.Errorf("imported revision by %s = %q", rev.AuthorUsername, rev.Content)
+	}
+	phantom := mustPage(t, dst, "dwarves")
+	if !phantom.IsPhantom || phantom.FirstCitedByUserID == nil || *phantom.FirstCitedByUserID != dstAlice.ID ||
+		phantom.FirstCitedInPageID == nil || *phantom.FirstCitedInPageID != page.ID {
+		t.Errorf("phantom = %+v, want cited by alice in elves", phantom)
+	}
+
+	// Conflicts follow the policy
+	for _, tt := range []struct {
+		policy    Policy
+		result    string
+		revisions int
+	}{
+		{Skip, Skipped, 1},
+		{NewRevision, Revised, 2},
+		{Overwrite, Overwritten, 1},
+	} {
+		report, err := Import(dst, a, Options{Fallback: admin, Policy: tt.policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Count(tt.result) != 1 {
+			t.Errorf("%s: report = %+v, want 1 %s", tt.policy, report.Actions, tt.result)
+		}
+		if n, _ := dst.RevisionCount(page.ID); n != tt.revisions {
+			t.Errorf("%s: %d revisions, want %d", tt.policy, n, tt.revisions)
+		}
+
+		// Discarded history doesn't take the page's links with it
+		if backlinks, err := dst.ListBacklinks("dwarves"); err != nil || len(backlinks) != 1 || backlinks[0].ID != page.ID {
+			t.Errorf("%s: backlinks of dwarves = %v, %v; want elves", tt.policy, backlinks, err)
+		}
+	}
+}
+
+func TestImportInvalidSlugs(t *testing.T) {
+	db := openTestDB(t, "dst.db")
+	admin, err := db.CreateUser("admin", "password123", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Archive{Entries: []*Entry{
+		{Slug: "Elves", Title: "Elves", Content: "Upper case."},
+		{Slug: "../admin", Title: "Admin", Content: "A path."},
+		{Slug: "???", Title: "Questions", Content: "Nothing left."},
+		{Slug: "dwarves", Title: "Dwarves", Content: "Fine."},
+	}}
+	report, err := Import(db, a, Options{Fallback: admin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(Failed) != 3 || report.Count(Created) != 1 {
+		t.Errorf("report = %+v, want 3 failed and 1 created", report.Actions)
+	}
+	for _, slug := range []string{"Elves", "elves", "../admin", "???"} {
+		if exists, _ := db.PageExists(slug); exists {
+			t.Errorf("created a page at %q", slug)
+		}
+	}
+}
+
+func mustPage(t *testing.T, db *database.DB, slug string) *database.Page {
+	t.Helper()
+
+	page, err := db.GetPageBySlug(slug)
+	if err != nil {
+		t.Fatalf("GetPageBySlug(%q): %v", slug, err)
+	}
+	return page
+}
+
+func TestExportHistory(t *testing.T) {
+	db := openTestDB(t, "history.db")
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := db.CreatePage("elves", "Elves", "First draft.", alice.ID, nil, database.RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdatePage(page.ID, "Elves", "Second draft.", alice.ID, nil, database.RevisionMeta{Summary: "Rewrite"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateComment(page.ID, alice.ID, "Nice."); err != nil {
+		t.Fatal(err)
+	}
+	gone, err := db.CreatePage("gone", "Gone", "Deleted later.", alice.ID, nil, database.RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SoftDeletePage(gone.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportHistory(context.Background(), db, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	var deleted bool
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record struct {
+			Type      string     `json:"type"`
+			Slug      string     `json:"slug"`
+			DeletedAt *time.Time `json:"deleted_at"`
+		}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		counts[record.Type]++
+		if record.Type == "page" && record.Slug == "gone" {
+			deleted = record.DeletedAt != nil
+		}
+	}
+
+	if counts["lexicon-history"] != 1 || counts["user"] != 1 || counts["page"] != 2 || counts["revision"] != 3 || counts["comment"] != 1 || counts["setting"] == 0 {
+		t.Errorf("record counts = %v", counts)
+	}
+	if !deleted {
+		t.Error("deleted page missing or not marked deleted")
+	}
+}
@@ -0,0 +1,127 @@
+// Package archive converts a wiki to and from the ZIP export format: one
+// markdown file with front matter per page plus a metadata.json.
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"lexicon/internal/database"
+)
+
+// Metadata is the contents of metadata.json.
+type Metadata struct {
+	ExportedAt    string            `json:"exported_at"`
+	WikiTitle     string            `json:"wiki_title"`
+	TotalPages    int               `json:"total_pages"`
+	TotalPhantoms int               `json:"total_phantoms"`
+	Phantoms      []PhantomMetadata `json:"phantoms"`
+}
+
+// PhantomMetadata records who first cited a phantom, and where.
+type PhantomMetadata struct {
+	Slug         string `json:"slug"`
+	Title        string `json:"title"`
+	FirstCitedBy string `json:"first_cited_by"`
+	FirstCitedIn string `json:"first_cited_in"`
+}
+
+// Export writes all pages as markdown, plus phantom metadata, as a ZIP.
+func Export(db *database.DB, w io.Writer) error {
+	wikiTitle, _ := db.WikiTitle()
+
+	zw := zip.NewWriter(w)
+
+	// Get all non-phantom pages
+	pages, err := db.ListPages()
+	if err != nil {
+		return err
+	}
+
+	// Get all phantoms
+	phantoms, err := db.ListPhantoms()
+	if err != nil {
+		return err
+	}
+
+	// Export each page
+	for _, page := range pages {
+		rev, err := db.GetCurrentRevision(page.ID)
+		if err != nil {
+			continue
+		}
+
+		revCount, _ := db.RevisionCount(page.ID)
+
+		content := fmt.Sprintf(`---
+title: %s
+slug: %s
+created: %s
+updated: %s
+author: %s
+revisions: %d
+---
+
+%s
+`,
+			page.Title,
+			page.Slug,
+			page.CreatedAt.Format(time.RFC3339),
+			page.UpdatedAt.Format(time.RFC3339),
+			rev.AuthorUsername,
+			revCount,
+			rev.Content,
+		)
+
+		f, err := zw.Create("pages/" + page.Slug + ".md")
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+
+	// Create metadata.json
+	metadata := Metadata{
+		ExportedAt:    time.Now().Format(time.RFC3339),
+		WikiTitle:     wikiTitle,
+		TotalPages:    len(pages),
+		TotalPhantoms: len(phantoms),
+	}
+
+	for _, phantom := range phantoms {
+		var citedBy, citedIn string
+		if phantom.FirstCitedByUserID != nil {
+			if user, err := db.GetUserByID(*phantom.FirstCitedByUserID); err == nil {
+				citedBy = user.Username
+			}
+		}
+		if phantom.FirstCitedInPageID != nil {
+			if page, err := db.GetPageByID(*phantom.FirstCitedInPageID); err == nil {
+				citedIn = page.Slug
+			}
+		}
+
+		metadata.Phantoms = append(metadata.Phantoms, PhantomMetadata{
+			Slug:         phantom.Slug,
+			Title:        phantom.Title,
+			FirstCitedBy: citedBy,
+			FirstCitedIn: citedIn,
+		})
+	}
+
+	metaJSON, _ := json.MarshalIndent(metadata, "", "  ")
+	f, err := zw.Create("metadata.json")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(metaJSON); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,173 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"lexicon/internal/database"
+)
+
+// HistoryVersion is the version of the full-history format, recorded in its
+// header line.
+const HistoryVersion = 1
+
+// The full-history export is JSON Lines: one object per line, each with a
+// "type" field. A header comes first, then settings, users, rounds, pages,
+// redirects, revisions and comments, so records only refer to ones before
+// them. Records refer to each other by the ids in this file.
+
+// HistoryHeader is the first line of a full-history export.
+type HistoryHeader struct {
+	Type       string    `json:"type"` // "lexicon-history"
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	WikiTitle  string    `json:"wiki_title"`
+}
+
+// HistorySetting is a wiki setting.
+type HistorySetting struct {
+	Type  string `json:"type"` // "setting"
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// HistoryUser is a user account. Password hashes are left out.
+type HistoryUser struct {
+	Type      string    `json:"type"` // "user"
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HistoryRound is a game round.
+type HistoryRound struct {
+	Type     string     `json:"type"` // "round"
+	ID       int64      `json:"id"`
+	Number   int        `json:"number"`
+	Label    string     `json:"label"`
+	Status   string     `json:"status"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	ClosedAt *time.Time `json:"closed_at,omitempty"`
+}
+
+// HistoryPage is a page, phantom or deleted page.
+type HistoryPage struct {
+	Type               string     `json:"type"` // "page"
+	ID                 int64      `json:"id"`
+	Slug               string     `json:"slug"`
+	Title              string     `json:"title"`
+	IsPhantom          bool       `json:"is_phantom,omitempty"`
+	FirstCitedByUserID *int64     `json:"first_cited_by_user_id,omitempty"`
+	FirstCitedInPageID *int64     `json:"first_cited_in_page_id,omitempty"`
+	RoundID            *int64     `json:"round_id,omitempty"`
+	Sealed             bool       `json:"sealed,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	DeletedAt          *time.Time `json:"deleted_at,omitempty"`
+}
+
+// HistoryRedirect is a former slug or alias of a page.
+type HistoryRedirect struct {
+	Type      string    `json:"type"` // "redirect"
+	FromSlug  string    `json:"from_slug"`
+	PageID    int64     `json:"page_id"`
+	IsAlias   bool      `json:"is_alias,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HistoryRevision is one revision of a page.
+type HistoryRevision struct {
+	Type       string    `json:"type"` // "revision"
+	ID         int64     `json:"id"`
+	PageID     int64     `json:"page_id"`
+	AuthorID   int64     `json:"author_id"`
+	Author     string    `json:"author"`
+	CreatedAt  time.Time `json:"created_at"`
+	Summary    string    `json:"summary,omitempty"`
+	IsMinor    bool      `json:"is_minor,omitempty"`
+	RevertOfID *int64    `json:"revert_of_id,omitempty"`
+	RoundID    *int64    `json:"round_id,omitempty"`
+	Content    string    `json:"content"`
+}
+
+// HistoryComment is a comment on a page.
+type HistoryComment struct {
+	Type      string    `json:"type"` // "comment"
+	ID        int64     `json:"id"`
+	PageID    int64     `json:"page_id"`
+	AuthorID  int64     `json:"author_id"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Content   string    `json:"content"`
+}
+
+// ExportHistory writes the complete wiki as JSON Lines, streaming records
+// as they are read.
+func ExportHistory(ctx context.Context, db *database.DB, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	wikiTitle, _ := db.WikiTitle()
+	err := enc.Encode(HistoryHeader{
+		Type:       "lexicon-history",
+		Version:    HistoryVersion,
+		ExportedAt: time.Now().UTC(),
+		WikiTitle:  wikiTitle,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = db.WalkHistory(ctx, database.HistoryVisitor{
+		Setting: func(key, value string) error {
+			return enc.Encode(HistorySetting{Type: "setting", Key: key, Value: value})
+		},
+		User: func(u *database.User) error {
+			return enc.Encode(HistoryUser{Type: "user", ID: u.ID, Username: u.Username, Role: u.Role, CreatedAt: u.CreatedAt})
+		},
+		Round: func(r *database.Round) error {
+			return enc.Encode(HistoryRound{
+				Type: "round", ID: r.ID, Number: r.Number, Label: r.Label, Status: r.Status,
+				Deadline: r.Deadline, OpenedAt: r.OpenedAt, ClosedAt: r.ClosedAt,
+			})
+		},
+		Page: func(p *database.Page) error {
+			return enc.Encode(HistoryPage{
+				Type: "page", ID: p.ID, Slug: p.Slug, Title: p.Title, IsPhantom: p.IsPhantom,
+				FirstCitedByUserID: p.FirstCitedByUserID, FirstCitedInPageID: p.FirstCitedInPageID,
+				RoundID: p.RoundID, Sealed: p.Sealed,
+				CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt, DeletedAt: p.DeletedAt,
+			})
+		},
+		Redirect: func(r *database.Redirect) error {
+			return enc.Encode(HistoryRedirect{
+				Type: "redirect", FromSlug: r.FromSlug, PageID: r.PageID, IsAlias: r.IsAlias, Title: r.Title, CreatedAt: r.CreatedAt,
+			})
+		},
+		Revision: func(r *database.Revision) error {
+			return enc.Encode(HistoryRevision{
+				Type: "revision", ID: r.ID, PageID: r.PageID, AuthorID: r.AuthorID, Author: r.AuthorUsername,
+				CreatedAt: r.CreatedAt, Summary: r.Summary, IsMinor: r.IsMinor, RevertOfID: r.RevertOfID, RoundID: r.RoundID,
+				Content: r.Content,
+			})
+		},
+		Comment: func(c *database.Comment) error {
+			return enc.Encode(HistoryComment{
+				Type: "comment", ID: c.ID, PageID: c.PageID, AuthorID: c.AuthorID, Author: c.AuthorUsername,
+				CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, Content: c.Content,
+			})
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
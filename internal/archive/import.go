@@ -0,0 +1,431 @@
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"lexicon/internal/database"
+	"lexicon/internal/markdown"
+)
+
+// Entry is a page read from an export.
+type Entry struct {
+	Slug    string
+	Title   string
+	Author  string
+	Content string
+}
+
+// Archive is the contents of an export ZIP.
+type Archive struct {
+	Entries  []*Entry // ordered by slug
+	Metadata Metadata
+}
+
+// ReadZip reads an export ZIP.
+func ReadZip(r io.ReaderAt, size int64) (*Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{}
+	for _, f := range zr.File {
+		if f.Name == "metadata.json" {
+			if err := readMetadata(f, &a.Metadata); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			continue
+		}
+		if path.Dir(f.Name) != "pages" || path.Ext(f.Name) != ".md" {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		entry, err := parseEntry(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if entry.Slug == "" {
+			entry.Slug = strings.TrimSuffix(path.Base(f.Name), ".md")
+		}
+		a.Entries = append(a.Entries, entry)
+	}
+
+	sort.Slice(a.Entries, func(i, j int) bool { return a.Entries[i].Slug < a.Entries[j].Slug })
+	return a, nil
+}
+
+func readMetadata(f *zip.File, m *Metadata) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(rc).Decode(m)
+}
+
+// parseEntry reads a page file: front matter between --- lines, a blank
+// line, then the content.
+func parseEntry(r io.Reader) (*Entry, error) {
+	br := bufio.NewReader(r)
+
+	line, err := br.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "---" {
+		return nil, errors.New("missing front matter")
+	}
+
+	entry := &Entry{}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, errors.New("unterminated front matter")
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "---" {
+			break
+		}
+
+		key, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "title":
+			entry.Title = value
+		case "slug":
+			entry.Slug = value
+		case "author":
+			entry.Author = value
+		}
+	}
+
+	body, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	content := strings.TrimPrefix(string(body), "\n")
+	entry.Content = strings.TrimSuffix(content, "\n")
+	return entry, nil
+}
+
+// Policy decides what happens to a page that is already written.
+type Policy string
+
+const (
+	// Skip leaves existing pages alone.
+	Skip Policy = "skip"
+	// NewRevision adds the imported content as a new revision, keeping the
+	// page's history.
+	NewRevision Policy = "new-revision"
+	// Overwrite replaces the page's content and discards its history.
+	Overwrite Policy = "overwrite"
+)
+
+// ParsePolicy returns the policy with the given name.
+func ParsePolicy(name string) (Policy, error) {
+	switch p := Policy(name); p {
+	case Skip, NewRevision, Overwrite:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (want skip, new-revision or overwrite)", name)
+}
+
+// Options control an import.
+type Options struct {
+	// Fallback is credited with pages and phantoms whose author doesn't
+	// exist in this wiki.
+	Fallback *database.User
+
+	Policy Policy
+
+	// DryRun reports what would happen without changing anything.
+	DryRun bool
+}
+
+// Results of importing a single page.
+const (
+	Created     = "created"
+	Skipped     = "skipped"
+	Revised     = "revised"
+	Overwritten = "overwritten"
+	Phantom     = "phantom"
+	Failed      = "failed"
+)
+
+// Action is what the import did, or would do, with one page.
+type Action struct {
+	Slug   string
+	Title  string
+	Result string
+	Author string
+	Note   string
+}
+
+// Report summarizes an import.
+type Report struct {
+	DryRun  bool
+	Actions []Action
+
+	// UnknownAuthors are usernames in the archive that were credited to
+	// the fallback user instead.
+	UnknownAuthors []string
+}
+
+// Count returns how many actions had the given result.
+func (r *Report) Count(result string) int {
+	n := 0
+	for _, a := range r.Actions {
+		if a.Result == result {
+			n++
+		}
+	}
+	return n
+}
+
+// importer holds the state of one import.
+type importer struct {
+	db      *database.DB
+	opts    Options
+	md      *markdown.Renderer
+	report  *Report
+	users   map[string]*database.User
+	unknown map[string]bool
+}
+
+// Import recreates the archive's pages and phantoms. Pages that are already
+// written are handled according to the conflict policy. Failures of single
+// pages are recorded in the report; the returned error is only for problems
+// that stop the whole import.
+func Import(db *database.DB, a *Archive, opts Options) (*Report, error) {
+	if opts.Fallback == nil {
+		return nil, errors.New("no fallback user")
+	}
+	if opts.Policy == "" {
+		opts.Policy = Skip
+	}
+
+	im := &importer{
+		db:      db,
+		opts:    opts,
+		md:      markdown.New(nil),
+		report:  &Report{DryRun: opts.DryRun},
+		users:   make(map[string]*database.User),
+		unknown: make(map[string]bool),
+	}
+
+	for _, entry := range a.Entries {
+		if err := im.importEntry(entry); err != nil {
+			return im.report, err
+		}
+	}
+	for _, phantom := range a.Metadata.Phantoms {
+		if err := im.importPhantom(phantom, a); err != nil {
+			return im.report, err
+		}
+	}
+
+	for name := range im.unknown {
+		im.report.UnknownAuthors = append(im.report.UnknownAuthors, name)
+	}
+	sort.Strings(im.report.UnknownAuthors)
+	return im.report, nil
+}
+
+// author returns the user called name, or the fallback user.
+func (im *importer) author(name string) (*database.User, error) {
+	if user, ok := im.users[name]; ok {
+		return user, nil
+	}
+
+	user, err := im.db.GetUserByUsername(name)
+	if err == database.ErrNotFound {
+		if name != "" {
+			im.unknown[name] = true
+		}
+		user, err = im.opts.Fallback, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	im.users[name] = user
+	return user, nil
+}
+
+func (im *importer) importEntry(entry *Entry) error {
+	title := entry.Title
+	if title == "" {
+		title = entry.Slug
+	}
+	author, err := im.author(entry.Author)
+	if err != nil {
+		return err
+	}
+	action := Action{Slug: entry.Slug, Title: title, Author: author.Username}
+	if note := badSlug(entry.Slug); note != "" {
+		action.Result = Failed
+		action.Note = note
+		im.report.Actions = append(im.report.Actions, action)
+		return nil
+	}
+
+	existing, err := im.db.GetPageBySlug(entry.Slug)
+	if err != nil && err != database.ErrNotFound {
+		return err
+	}
+
+	switch {
+	case existing == nil || existing.IsPhantom:
+		action.Result = Created
+	case existing.DeletedAt != nil:
+		action.Result = Skipped
+		action.Note = "a deleted page has this slug"
+	case im.opts.Policy == NewRevision:
+		action.Result = Revised
+	case im.opts.Policy == Overwrite:
+		action.Result = Overwritten
+	default:
+		action.Result = Skipped
+		action.Note = "already exists"
+	}
+
+	if !im.opts.DryRun {
+		if err := im.write(entry, title, author, existing, action.Result); err != nil {
+			action.Result = Failed
+			action.Note = err.Error()
+		}
+	}
+
+	im.report.Actions = append(im.report.Actions, action)
+	return nil
+}
+
+// badSlug explains why a slug from an archive isn't one this wiki would
+// make from a title, or returns "" if it's fine.
+func badSlug(slug string) string {
+	normalized := database.Slugify(slug)
+	switch {
+	case normalized == "":
+		return "invalid slug"
+	case normalized != slug:
+		return fmt.Sprintf("invalid slug (would be %q)", normalized)
+	}
+	return ""
+}
+
+// write saves an entry as the given result decided.
+func (im *importer) write(entry *Entry, title string, author *database.User, existing *database.Page, result string) error {
+	links := im.md.ExtractLinks(entry.Content)
+	meta := database.RevisionMeta{Summary: "Imported"}
+
+	var pageID int64
+	switch result {
+	case Created:
+		page, err := im.db.CreatePage(entry.Slug, title, entry.Content, author.ID, markdown.DatabaseLinks(links), meta)
+		if err == database.ErrClaimed {
+			return errors.New("claimed by another player")
+		}
+		if err != nil {
+			return err
+		}
+		pageID = page.ID
+	case Revised, Overwritten:
+		if err := im.db.UpdatePage(existing.ID, title, entry.Content, author.ID, markdown.DatabaseLinks(links), meta); err != nil {
+			return err
+		}
+		if result == Overwritten {
+			if err := im.db.DiscardHistory(existing.ID); err != nil {
+				return err
+			}
+		}
+		pageID = existing.ID
+	default:
+		return nil
+	}
+
+	// Cited pages that don't exist (yet) become phantoms, as on save
+	for _, target := range markdown.UniqueTargets(links) {
+		if exists, err := im.db.PageExists(target); err != nil || exists {
+			continue
+		}
+		for _, link := range links {
+			if link.Target == target {
+				im.db.CreatePhantom(target, link.DisplayText, author.ID, pageID)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// importPhantom creates a phantom from the archive's metadata, or restores
+// the citation details of one the imported pages created. Phantoms whose
+// citing page isn't here are skipped, since nothing would link to them.
+func (im *importer) importPhantom(pm PhantomMetadata, a *Archive) error {
+	citedBy, err := im.author(pm.FirstCitedBy)
+	if err != nil {
+		return err
+	}
+	action := Action{Slug: pm.Slug, Title: pm.Title, Result: Phantom, Author: citedBy.Username}
+	if note := badSlug(pm.Slug); note != "" {
+		action.Result = Failed
+		action.Note = note
+		im.report.Actions = append(im.report.Actions, action)
+		return nil
+	}
+
+	existing, err := im.db.GetPageBySlug(pm.Slug)
+	if err != nil && err != database.ErrNotFound {
+		return err
+	}
+	if existing != nil && !existing.IsPhantom {
+		// Written since the export, here or by this import
+		return nil
+	}
+
+	var source *database.Page
+	if page, err := im.db.GetPageBySlug(pm.FirstCitedIn); err == nil && !page.IsPhantom {
+		source = page
+	}
+	if source == nil && !(im.opts.DryRun && im.inArchive(pm.FirstCitedIn, a)) {
+		action.Result = Skipped
+		action.Note = "the page citing it isn't here"
+		im.report.Actions = append(im.report.Actions, action)
+		return nil
+	}
+
+	if !im.opts.DryRun {
+		if existing == nil {
+			existing, err = im.db.CreatePhantom(pm.Slug, pm.Title, citedBy.ID, source.ID)
+		}
+		if err == nil {
+			err = im.db.SetPhantomCitation(existing.ID, &citedBy.ID, &source.ID)
+		}
+		if err != nil {
+			action.Result = Failed
+			action.Note = err.Error()
+		}
+	}
+
+	im.report.Actions = append(im.report.Actions, action)
+	return nil
+}
+
+// inArchive reports whether the archive has a page with the given slug.
+func (im *importer) inArchive(slug string, a *Archive) bool {
+	for _, e := range a.Entries {
+		if e.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+// Package backup takes verified snapshots of the wiki database, rotates old
+// ones and restores them.
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"lexicon/internal/database"
+)
+
+const (
+	prefix     = "lexicon-"
+	suffix     = ".db"
+	timeFormat = "20060102-150405"
+)
+
+// ErrNotFound is returned for a backup name that isn't in the directory.
+var ErrNotFound = errors.New("backup not found")
+
+// File is a backup in the backup directory.
+type File struct {
+	Name      string
+	Path      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// HumanSize returns the file size in readable units.
+func (f *File) HumanSize() string {
+	const unit = 1024
+	if f.Size < unit {
+		return fmt.Sprintf("%d B", f.Size)
+	}
+	div, exp := int64(unit), 0
+	for n := f.Size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(f.Size)/float64(div), "KMGT"[exp])
+}
+
+// Create snapshots the database into dir, checks the snapshot's integrity,
+// then deletes all but the newest keep backups.
+func Create(db *database.DB, dir string, keep int) (*File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	name := prefix + now.Format(timeFormat) + suffix
+	path := filepath.Join(dir, name)
+
+	// Snapshot to a temporary name so a failed or corrupt backup never
+	// shows up in the list
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := db.BackupTo(tmp); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("snapshot failed: %w", err)
+	}
+	if err := database.CheckIntegrity(tmp); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	if _, err := Prune(dir, keep); err != nil {
+		return nil, err
+	}
+	return Get(dir, name)
+}
+
+// List returns the backups in dir, newest first.
+func List(dir string) ([]*File, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*File
+	for _, entry := range entries {
+		created, ok := parseName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &File{
+			Name:      entry.Name(),
+			Path:      filepath.Join(dir, entry.Name()),
+			Size:      info.Size(),
+			CreatedAt: created,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+	return files, nil
+}
+
+// Get returns the backup with the given name from dir. Only names of
+// backups are accepted, so name can come from a URL.
+func Get(dir, name string) (*File, error) {
+	files, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Prune deletes all but the newest keep backups in dir and returns how many
+// it deleted.
+func Prune(dir string, keep int) (int, error) {
+	files, err := List(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i := keep; i < len(files); i++ {
+		if err := os.Remove(files[i].Path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Restore replaces the database at dbPath with the backup at src after
+// checking the backup's integrity. The current database is kept next to it
+// with a .before-restore suffix. The server must not be running.
+func Restore(src, dbPath string) error {
+	if err := database.CheckIntegrity(src); err != nil {
+		return err
+	}
+
+	// Copy first so a failure leaves the current database untouched
+	tmp := dbPath + ".restore"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Move the write-ahead log along with the old database, so anything not
+	// yet checkpointed stays with it
+	old := dbPath + ".before-restore"
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, old); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	os.Remove(old + "-wal")
+	if err := os.Rename(dbPath+"-wal", old+"-wal"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	os.Remove(dbPath + "-shm")
+
+	return os.Rename(tmp, dbPath)
+}
+
+func parseName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+	t, err := time.Parse(timeFormat, stamp)
+	return t, err == nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lexicon/internal/database"
+)
+
+func TestCreateAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "lexicon.db")
+	backupDir := filepath.Join(dir, "backups")
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSetting("wiki_title", "Before"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Create(db, backupDir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSetting("wiki_title", "After"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Older backups beyond the limit are rotated out
+	for _, name := range []string{"lexicon-20200101-000000.db", "lexicon-20210101-000000.db"} {
+		if err := os.WriteFile(filepath.Join(backupDir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Prune(backupDir, 2); err != nil {
+		t.Fatal(err)
+	}
+	files, err := List(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Name != f.Name || files[1].Name != "lexicon-20210101-000000.db" {
+		t.Errorf("backups after pruning = %v, want %s and the 2021 one", files, f.Name)
+	}
+
+	// A corrupt backup is refused
+	if err := Restore(files[1].Path, dbPath); err == nil {
+		t.Error("Restore of an empty file succeeded")
+	}
+
+	if err := Restore(f.Path, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	db, err = database.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if title, _ := db.WikiTitle(); title != "Before" {
+		t.Errorf("restored wiki title = %q, want Before", title)
+	}
+	if _, err := os.Stat(dbPath + ".before-restore"); err != nil {
+		t.Errorf("previous database wasn't kept: %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+// Package book lays a finished wiki out as a book — title page, contributors,
+// entries in reading order and an appendix of unwritten phantoms — and
+// writes it as an EPUB or as a single HTML document.
+package book
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"lexicon/internal/database"
+)
+
+// Order decides how entries are arranged in the book.
+type Order string
+
+const (
+	// ByTitle arranges entries alphabetically, in one section per letter.
+	ByTitle Order = "title"
+	// ByRound arranges entries in the round they were written in.
+	ByRound Order = "round"
+)
+
+// ParseOrder returns the order with the given name.
+func ParseOrder(name string) (Order, error) {
+	switch o := Order(name); o {
+	case ByTitle, ByRound:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown order %q (want title or round)", name)
+}
+
+// Book is the wiki ready to be written out.
+type Book struct {
+	Title        string
+	Intro        string // markdown of the home page, if written
+	Sections     []*Section
+	Phantoms     []*database.PhantomWithSource
+	Contributors []*Contributor
+	GeneratedAt  time.Time
+
+	db *database.DB
+	// entries maps slugs to entries, for resolving links
+	entries map[string]*Entry
+	// phantoms holds the slugs listed in the appendix
+	phantoms map[string]bool
+	// redirects caches where aliases and former slugs point, "" if nowhere
+	redirects map[string]string
+}
+
+// Section is a run of entries under one heading.
+type Section struct {
+	Heading string
+	Entries []*Entry
+
+	// File is the section's document in an EPUB. Its name also anchors
+	// the section in a single HTML document.
+	File string
+}
+
+// Entry is one written page.
+type Entry struct {
+	Slug    string
+	Title   string
+	Author  string
+	Round   string // label of the round it was written in, if any
+	Content string // markdown of the current revision
+
+	section *Section
+}
+
+// Contributor is someone who wrote entries.
+type Contributor struct {
+	Name     string // player persona, or username if there is none
+	Username string
+	Entries  int
+}
+
+// Build gathers the wiki's current pages into a book.
+func Build(db *database.DB, order Order) (*Book, error) {
+	title, err := db.WikiTitle()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Book{
+		Title:       title,
+		GeneratedAt: time.Now(),
+		db:          db,
+		entries:     make(map[string]*Entry),
+		phantoms:    make(map[string]bool),
+		redirects:   make(map[string]string),
+	}
+
+	names, err := authorNames(db)
+	if err != nil {
+		return nil, err
+	}
+	rounds, err := db.ListRounds()
+	if err != nil {
+		return nil, err
+	}
+	roundLabels := make(map[int64]string)
+	for _, r := range rounds {
+		roundLabels[r.ID] = r.Label
+	}
+
+	pages, err := db.ListPages()
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	roundOf := make(map[*Entry]int64)
+	contributors := make(map[int64]*Contributor)
+	for _, page := range pages {
+		// Redirect pages aren't entries; links to them go to their target
+		if page.RedirectTo != "" {
+			continue
+		}
+		rev, err := db.GetCurrentRevision(page.ID)
+		if err != nil {
+			return nil, fmt.Errorf("page %s: %w", page.Slug, err)
+		}
+		if page.Slug == "home-page" {
+			b.Intro = rev.Content
+			continue
+		}
+
+		authorID, err := db.PageAuthor(page.ID)
+		if err != nil {
+			return nil, fmt.Errorf("page %s: %w", page.Slug, err)
+		}
+		c := contributors[authorID]
+		if c == nil {
+			c = &Contributor{Name: names[authorID].Name, Username: names[authorID].Username}
+			contributors[authorID] = c
+		}
+		c.Entries++
+
+		entry := &Entry{Slug: page.Slug, Title: page.Title, Author: c.Name, Content: rev.Content}
+		if page.RoundID != nil {
+			entry.Round = roundLabels[*page.RoundID]
+			roundOf[entry] = *page.RoundID
+		}
+		entries = append(entries, entry)
+		b.entries[page.Slug] = entry
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Title) < strings.ToLower(entries[j].Title)
+	})
+
+	switch order {
+	case ByRound:
+		byRound := make(map[int64]*Section)
+		for _, r := range rounds {
+			s := &Section{Heading: "Round " + r.Label}
+			byRound[r.ID] = s
+			b.Sections = append(b.Sections, s)
+		}
+		other := &Section{Heading: "Other Entries"}
+		for _, e := range entries {
+			s := other
+			if id, ok := roundOf[e]; ok {
+				s = byRound[id]
+			}
+			s.Entries = append(s.Entries, e)
+		}
+		b.Sections = append(b.Sections, other)
+	default:
+		var s *Section
+		for _, e := range entries {
+			if heading := initial(e.Title); s == nil || s.Heading != heading {
+				s = &Section{Heading: heading}
+				b.Sections = append(b.Sections, s)
+			}
+			s.Entries = append(s.Entries, e)
+		}
+	}
+
+	// Drop empty rounds, then number the rest
+	sections := b.Sections[:0]
+	for _, s := range b.Sections {
+		if len(s.Entries) > 0 {
+			s.File = fmt.Sprintf("section-%d.xhtml", len(sections)+1)
+			for _, e := range s.Entries {
+				e.section = s
+			}
+			sections = append(sections, s)
+		}
+	}
+	b.Sections = sections
+
+	if b.Phantoms, err = db.ListPhantomsWithSource(); err != nil {
+		return nil, err
+	}
+	for _, p := range b.Phantoms {
+		b.phantoms[p.Slug] = true
+	}
+
+	for _, c := range contributors {
+		b.Contributors = append(b.Contributors, c)
+	}
+	sort.Slice(b.Contributors, func(i, j int) bool {
+		return strings.ToLower(b.Contributors[i].Name) < strings.ToLower(b.Contributors[j].Name)
+	})
+
+	return b, nil
+}
+
+// authorNames returns how each user is credited: by their player persona if
+// they have one, otherwise by username.
+func authorNames(db *database.DB) (map[int64]Contributor, error) {
+	users, err := db.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	players, err := db.ListPlayers()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int64]Contributor, len(users))
+	for _, u := range users {
+		names[u.ID] = Contributor{Name: u.Username, Username: u.Username}
+	}
+	for _, p := range players {
+		if p.DisplayName != "" {
+			names[p.UserID] = Contributor{Name: p.DisplayName, Username: p.Username}
+		}
+	}
+	return names, nil
+}
+
+// initial returns the letter an entry is filed under, or "#" for titles that
+// don't start with one.
+func initial(title string) string {
+	for _, r := range title {
+		if unicode.IsLetter(r) {
+			return string(unicode.ToUpper(r))
+		}
+		return "#"
+	}
+	return "#"
+}
+
+// ID returns the entry's anchor. Slugs may start with a digit, which XML
+// IDs may not.
+func (e *Entry) ID() string {
+	return "entry-" + e.Slug
+}
+
+// ID returns the section's anchor.
+func (s *Section) ID() string {
+	return strings.TrimSuffix(s.File, ".xhtml")
+}
+
+// PhantomID returns the anchor of a phantom in the appendix.
+func PhantomID(slug string) string {
+	return "phantom-" + slug
+}
+
+// resolve returns the entry a link to slug leads to, following redirect
+// pages, aliases and former slugs, or nil if it isn't in the book.
+func (b *Book) resolve(slug string) *Entry {
+	if e, ok := b.entries[slug]; ok {
+		return e
+	}
+	target, ok := b.redirects[slug]
+	if !ok {
+		if page, err := b.db.GetPageBySlug(slug); err == nil && page.RedirectTo != "" {
+			target = page.RedirectTo
+		} else if page, err := b.db.ResolveRedirect(slug); err == nil {
+			target = page.Slug
+		}
+		b.redirects[slug] = target
+	}
+	return b.entries[target]
+}
+
+// pageStatus styles links to anything outside the book as phantoms.
+func (b *Book) pageStatus(slug string) (exists bool, isPhantom bool) {
+	e := b.resolve(slug)
+	return e != nil, e == nil
+}
@@ -0,0 +1,137 @@
+package book
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lexicon/internal/database"
+)
+
+func testBook(t *testing.T, order Order) *Book {
+	t.Helper()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SavePlayer(alice.ID, "Prof. Alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePage("dwarves", "Dwarves", "Miners of the deep.\n\n---", alice.ID, nil, database.RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	content := "Elves distrust [[Dwarves]] & fear [[Orcs]]."
+	links := []database.Link{{TargetSlug: "dwarves", DisplayText: "Dwarves"}, {TargetSlug: "orcs", DisplayText: "Orcs"}}
+	elves, err := db.CreatePage("elves", "Elves", content, alice.ID, links, database.RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePhantom("orcs", "Orcs", alice.ID, elves.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Build(db, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBuild(t *testing.T) {
+	b := testBook(t, ByTitle)
+
+	if len(b.Sections) != 2 || b.Sections[0].Heading != "D" || b.Sections[1].Heading != "E" {
+		t.Fatalf("sections = %+v", b.Sections)
+	}
+	if len(b.Contributors) != 1 || b.Contributors[0].Name != "Prof. Alice" || b.Contributors[0].Entries != 2 {
+		t.Errorf("contributors = %+v", b.Contributors)
+	}
+
+	b = testBook(t, ByRound)
+	if len(b.Sections) != 1 || len(b.Sections[0].Entries) != 2 {
+		t.Errorf("without rounds, want one section of both entries, got %+v", b.Sections)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	b := testBook(t, ByTitle)
+
+	var buf bytes.Buffer
+	if err := b.WriteHTML(&buf, os.DirFS("../../templates")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<a href="#entry-dwarves" class="wiki-link">Dwarves</a>`,
+		`<a href="#phantom-orcs" class="wiki-link phantom">Orcs</a>`,
+		`<li id="phantom-orcs">`,
+		`a.wiki-link.phantom`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("book lacks %s", want)
+		}
+	}
+}
+
+func TestWriteEPUB(t *testing.T) {
+	b := testBook(t, ByTitle)
+
+	var buf bytes.Buffer
+	if err := b.WriteEPUB(&buf, os.DirFS("../../templates")); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := zr.File[0]; f.Name != "mimetype" || f.Method != zip.Store {
+		t.Errorf("first entry is %s (method %d), want stored mimetype", f.Name, f.Method)
+	}
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(data)
+
+		// Readers reject documents that aren't well-formed XML
+		if strings.HasSuffix(f.Name, ".xhtml") || strings.HasSuffix(f.Name, ".opf") {
+			if !strings.HasPrefix(files[f.Name], xml.Header) {
+				t.Errorf("%s lacks an XML declaration", f.Name)
+			}
+			d := xml.NewDecoder(bytes.NewReader(data))
+			for {
+				if _, err := d.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("%s: %v", f.Name, err)
+				}
+			}
+		}
+	}
+
+	if !strings.Contains(files["OEBPS/section-2.xhtml"], `<a href="section-1.xhtml#entry-dwarves" class="wiki-link">`) {
+		t.Errorf("section-2.xhtml = %s", files["OEBPS/section-2.xhtml"])
+	}
+	if !strings.Contains(files["OEBPS/content.opf"], `<dc:creator>Prof. Alice</dc:creator>`) {
+		t.Errorf("content.opf = %s", files["OEBPS/content.opf"])
+	}
+}
@@ -0,0 +1,208 @@
+package book
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+
+	"lexicon/internal/markdown"
+	"lexicon/internal/markdown/wikilink"
+)
+
+const (
+	phantomsFile = "phantoms.xhtml"
+
+	containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+    <rootfiles>
+        <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+    </rootfiles>
+</container>
+`
+)
+
+// templateData is what the book templates see: the book plus the details
+// only one format needs.
+type templateData struct {
+	*Book
+	CSS        template.CSS
+	Identifier string
+	Modified   string
+}
+
+// WriteHTML writes the book as one self-contained HTML document, using the
+// templates under book/ in tmplFS.
+func (b *Book) WriteHTML(w io.Writer, tmplFS fs.FS) error {
+	tmpl, css, err := b.templates(tmplFS, false)
+	if err != nil {
+		return err
+	}
+	return tmpl.ExecuteTemplate(w, "book", templateData{Book: b, CSS: template.CSS(css)})
+}
+
+// WriteEPUB writes the book as an EPUB 3 file, using the templates under
+// book/ in tmplFS.
+func (b *Book) WriteEPUB(w io.Writer, tmplFS fs.FS) error {
+	tmpl, css, err := b.templates(tmplFS, true)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid()
+	if err != nil {
+		return err
+	}
+	data := templateData{
+		Book:       b,
+		Identifier: "urn:uuid:" + id,
+		Modified:   b.GeneratedAt.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+
+	zw := zip.NewWriter(w)
+
+	// The mimetype must come first and be stored uncompressed, so readers
+	// can recognize the file by its leading bytes
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mw, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	write := func(name, content string) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, content)
+		return err
+	}
+	// html/template would escape an XML declaration, so it's written here
+	execute := func(name, tmplName string, data any) error {
+		f, err := zw.Create("OEBPS/" + name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xml.Header); err != nil {
+			return err
+		}
+		if err := tmpl.ExecuteTemplate(f, tmplName, data); err != nil {
+			return fmt.Errorf("rendering %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := write("META-INF/container.xml", containerXML); err != nil {
+		return err
+	}
+	if err := write("OEBPS/book.css", css); err != nil {
+		return err
+	}
+	if err := execute("content.opf", "epub-opf", data); err != nil {
+		return err
+	}
+	if err := execute("nav.xhtml", "epub-nav", data); err != nil {
+		return err
+	}
+	if err := execute("title.xhtml", "epub-title", data); err != nil {
+		return err
+	}
+	if b.Intro != "" {
+		if err := execute("intro.xhtml", "epub-intro", data); err != nil {
+			return err
+		}
+	}
+	for _, s := range b.Sections {
+		if err := execute(s.File, "epub-section", s); err != nil {
+			return err
+		}
+	}
+	if err := execute(phantomsFile, "epub-appendix", data); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// templates loads the book templates and stylesheet. Links are rendered for
+// an EPUB, where each section is its own document, or for a single page.
+func (b *Book) templates(tmplFS fs.FS, epub bool) (*template.Template, string, error) {
+	md := markdown.New(nil)
+	linkURL := b.linkURL(epub)
+
+	funcMap := template.FuncMap{
+		"render": func(content string) (template.HTML, error) {
+			var s string
+			var err error
+			if epub {
+				s, err = md.RenderXHTML(content, b.pageStatus, linkURL)
+			} else {
+				s, err = md.RenderLinked(content, b.pageStatus, linkURL)
+			}
+			return template.HTML(s), err
+		},
+		"entryURL": func(slug string) string {
+			if e := b.entries[slug]; e != nil {
+				return b.href(epub, e.section.File, e.ID())
+			}
+			return ""
+		},
+		"phantomID": PhantomID,
+	}
+
+	tmpl := template.New("book").Funcs(funcMap)
+	for _, name := range []string{"book/parts.html", "book/book.html", "book/epub.xhtml"} {
+		content, err := fs.ReadFile(tmplFS, name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := tmpl.Parse(string(content)); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	css, err := fs.ReadFile(tmplFS, "book/book.css")
+	if err != nil {
+		return nil, "", err
+	}
+	return tmpl, string(css), nil
+}
+
+// linkURL points wiki links at entries in the book, and everything else at
+// the appendix.
+func (b *Book) linkURL(epub bool) wikilink.LinkURL {
+	return func(slug string) string {
+		if e := b.resolve(slug); e != nil {
+			return b.href(epub, e.section.File, e.ID())
+		}
+		if b.phantoms[slug] {
+			return b.href(epub, phantomsFile, PhantomID(slug))
+		}
+		return b.href(epub, phantomsFile, "phantoms")
+	}
+}
+
+// href returns a link to the anchor id in file, or just to the anchor when
+// the whole book is one document.
+func (b *Book) href(epub bool, file, id string) string {
+	if epub {
+		return file + "#" + id
+	}
+	return "#" + id
+}
+
+// uuid returns a random version 4 UUID.
+func uuid() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
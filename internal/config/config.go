@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config holds all application configuration loaded from environment variables.
@@ -27,6 +28,10 @@ type Config struct {
 	// Port to listen on (defaults to 443 for HTTPS, 8080 for HTTP)
 	Port int
 
+	// Public address of the wiki (e.g., https://wiki.example.com), for links
+	// sent outside a request. Derived from Domain and Port if empty.
+	PublicURL string
+
 	// Optional: admin credentials for first-run setup
 	AdminUsername string
 	AdminPassword string
@@ -34,12 +39,29 @@ type Config struct {
 
 // Load reads configuration from environment variables and validates required fields.
 func Load() (*Config, error) {
+	cfg, err := LoadForCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// LoadForCommand reads configuration from environment variables without
+// requiring the settings only the web server needs, for admin commands that
+// just open the database.
+func LoadForCommand() (*Config, error) {
 	cfg := &Config{
 		Domain:        os.Getenv("LEXICON_DOMAIN"),
 		DataDir:       getEnvDefault("LEXICON_DATA_DIR", "./data"),
 		SessionSecret: os.Getenv("LEXICON_SESSION_SECRET"),
 		AdminEmail:    os.Getenv("LEXICON_ADMIN_EMAIL"),
 		HTTPMode:      os.Getenv("LEXICON_HTTP_MODE") == "true",
+		PublicURL:     strings.TrimSuffix(os.Getenv("LEXICON_BASE_URL"), "/"),
 		AdminUsername: os.Getenv("LEXICON_ADMIN_USERNAME"),
 		AdminPassword: os.Getenv("LEXICON_ADMIN_PASSWORD"),
 	}
@@ -60,10 +82,6 @@ func Load() (*Config, error) {
 		}
 	}
 
-	if err := cfg.validate(); err != nil {
-		return nil, err
-	}
-
 	return cfg, nil
 }
 
@@ -92,11 +110,35 @@ func (c *Config) ListenAddr() string {
 	return fmt.Sprintf(":%d", c.Port)
 }
 
+// BaseURL returns the wiki's public address, for links in messages sent
+// from background jobs, where there is no request to take it from.
+func (c *Config) BaseURL() string {
+	if c.PublicURL != "" {
+		return c.PublicURL
+	}
+	host := c.Domain
+	if host == "" {
+		host = "localhost"
+	}
+	if c.HTTPMode {
+		return fmt.Sprintf("http://%s:%d", host, c.Port)
+	}
+	if c.Port != 443 {
+		return fmt.Sprintf("https://%s:%d", host, c.Port)
+	}
+	return "https://" + host
+}
+
 // DatabasePath returns the full path to the SQLite database file.
 func (c *Config) DatabasePath() string {
 	return c.DataDir + "/lexicon.db"
 }
 
+// BackupDir returns the directory database backups are written to.
+func (c *Config) BackupDir() string {
+	return c.DataDir + "/backups"
+}
+
 // AutocertDir returns the directory for Let's Encrypt certificate cache.
 func (c *Config) AutocertDir() string {
 	return c.DataDir + "/autocert"
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// BackupTo writes a consistent snapshot of the database to path, which must
+// not exist. It is safe to call while the server is running.
+func (db *DB) BackupTo(path string) error {
+	_, err := db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// CheckIntegrity runs SQLite's integrity check on the database file at path
+// without migrating it.
+func CheckIntegrity(path string) error {
+	sqlDB, err := sql.Open("sqlite", path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	rows, err := sqlDB.Query("PRAGMA integrity_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %v", problems)
+	}
+
+	// An empty or foreign file passes the check, so make sure it is a wiki
+	var pages int
+	if err := sqlDB.QueryRow("SELECT COUNT(*) FROM pages").Scan(&pages); err != nil {
+		return fmt.Errorf("not a wiki database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import "time"
+
+// Change kinds in the recent-changes stream.
+const (
+	ChangeEdit    = "edit"
+	ChangeComment = "comment"
+)
+
+// Change is one entry in the recent-changes stream: a page revision or a new
+// comment.
+type Change struct {
+	Kind           string
+	ID             int64 // revision or comment ID
+	PageID         int64
+	PageSlug       string
+	PageTitle      string
+	AuthorID       int64
+	AuthorUsername string
+	Summary        string // edit summary, or the start of a comment
+	IsMinor        bool
+	IsNewPage      bool
+	Size           int // content length in characters after an edit
+	SizeDelta      int // change in content length from the previous revision
+	CreatedAt      time.Time
+}
+
+// ChangeFilter narrows the recent-changes stream. Zero values match everything.
+type ChangeFilter struct {
+	Username  string
+	PageSlug  string
+	Since     time.Time // inclusive
+	Until     time.Time // exclusive
+	HideMinor bool
+	Limit     int
+	Offset    int
+}
+
+// ListChanges returns revisions and comments on non-deleted pages, newest
+// first.
+func (db *DB) ListChanges(f ChangeFilter) ([]*Change, error) {
+	until := f.Until
+	if until.IsZero() {
+		until = time.Now().AddDate(100, 0, 0)
+	}
+
+	rows, err := db.Query(`
+		SELECT * FROM (
+			SELECT 'edit' AS kind, r.id, p.id AS page_id, p.slug, p.title, u.id AS author_id, u.username,
+				r.summary, r.is_minor, length(r.content) AS size,
+				(SELECT length(prev.content) FROM revisions prev
+				 WHERE prev.page_id = r.page_id AND prev.id < r.id
+				 ORDER BY prev.id DESC LIMIT 1) AS prev_size,
+				r.created_at
+			FROM revisions r
+			JOIN pages p ON r.page_id = p.id
+			JOIN users u ON r.author_id = u.id
+			WHERE p.is_phantom = 0 AND p.deleted_at IS NULL AND p.sealed = 0
+			UNION ALL
+			SELECT 'comment', c.id, p.id, p.slug, p.title, u.id, u.username,
+				substr(c.content, 1, 200), 0, 0, 0, c.created_at
+			FROM comments c
+			JOIN pages p ON c.page_id = p.id
+			JOIN users u ON c.author_id = u.id
+			WHERE p.is_phantom = 0 AND p.deleted_at IS NULL AND p.sealed = 0
+		)
+		WHERE (? = '' OR username = ?)
+			AND (? = '' OR slug = ?)
+			AND created_at >= ? AND created_at < ?
+			AND (? = 0 OR is_minor = 0)
+		ORDER BY created_at DESC, kind DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, f.Username, f.Username, f.PageSlug, f.PageSlug, f.Since, until, f.HideMinor, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*Change
+	for rows.Next() {
+		c := &Change{}
+		var prevSize *int
+		err := rows.Scan(
+			&c.Kind, &c.ID, &c.PageID, &c.PageSlug, &c.PageTitle, &c.AuthorID, &c.AuthorUsername,
+			&c.Summary, &c.IsMinor, &c.Size, &prevSize, &c.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if c.Kind == ChangeEdit {
+			c.IsNewPage = prevSize == nil
+			if prevSize != nil {
+				c.SizeDelta = c.Size - *prevSize
+			} else {
+				c.SizeDelta = c.Size
+			}
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListChanges(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := db.CreateUser("bob", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := db.CreatePage("dragons", "Dragons", "Dragons.", alice.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.UpdatePage(page.ID, "Dragons", "Dragons breathe fire.", bob.ID, nil, RevisionMeta{Summary: "Expand"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.UpdatePage(page.ID, "Dragons", "Dragons breathe fire!", alice.ID, nil, RevisionMeta{IsMinor: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateComment(page.ID, bob.ID, "Nice entry"); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := db.ListChanges(ChangeFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 4 {
+		t.Fatalf("got %d changes, want 4", len(changes))
+	}
+	if changes[0].Kind != ChangeComment || changes[0].Summary != "Nice entry" {
+		t.Errorf("newest change = %+v, want the comment", changes[0])
+	}
+	if c := changes[2]; c.Summary != "Expand" || c.SizeDelta != 13 || c.IsNewPage {
+		t.Errorf("expand edit = %+v, want size delta 13", c)
+	}
+	if c := changes[3]; !c.IsNewPage || c.SizeDelta != len("Dragons.") {
+		t.Errorf("creation = %+v, want new page", c)
+	}
+
+	changes, err = db.ListChanges(ChangeFilter{Username: "bob", HideMinor: true, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("bob's changes = %d, want 2", len(changes))
+	}
+
+	changes, err = db.ListChanges(ChangeFilter{Since: time.Now().Add(time.Hour), Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("future changes = %d, want 0", len(changes))
+	}
+
+	changes, err = db.ListChanges(ChangeFilter{PageSlug: "dragons", Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || !changes[1].IsNewPage {
+		t.Errorf("second page of changes = %d, want the two oldest", len(changes))
+	}
+}
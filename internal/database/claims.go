@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrClaimed is returned when a phantom is reserved by another user.
+var ErrClaimed = errors.New("page claimed by another user")
+
+// Claim is a user's reservation of a phantom they intend to write.
+type Claim struct {
+	PageID    int64
+	UserID    int64
+	ClaimedAt time.Time
+	ExpiresAt time.Time
+
+	// Joined fields
+	Username string
+}
+
+// GetClaim returns the unexpired claim on a page, or ErrNotFound.
+func (db *DB) GetClaim(pageID int64) (*Claim, error) {
+	claim := &Claim{}
+	err := db.QueryRow(`
+		SELECT c.page_id, c.user_id, c.claimed_at, c.expires_at, u.username
+		FROM claims c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.page_id = ? AND c.expires_at > ?
+	`, pageID, time.Now()).Scan(&claim.PageID, &claim.UserID, &claim.ClaimedAt, &claim.ExpiresAt, &claim.Username)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// ClaimPage reserves a phantom for a user for the given duration. Claiming
+// again renews the user's own claim; a live claim by anyone else fails with
+// ErrClaimed.
+func (db *DB) ClaimPage(pageID, userID int64, duration time.Duration) (*Claim, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var holder int64
+	err = tx.QueryRow(`
+		SELECT user_id FROM claims WHERE page_id = ? AND expires_at > ?
+	`, pageID, now).Scan(&holder)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && holder != userID {
+		return nil, ErrClaimed
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO claims (page_id, user_id, claimed_at, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (page_id) DO UPDATE SET
+			user_id = excluded.user_id, claimed_at = excluded.claimed_at, expires_at = excluded.expires_at
+	`, pageID, userID, now, now.Add(duration))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetClaim(pageID)
+}
+
+// CleanExpiredClaims removes expired claims and returns how many were
+// removed.
+func (db *DB) CleanExpiredClaims() (int64, error) {
+	result, err := db.Exec("DELETE FROM claims WHERE expires_at <= ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ReleaseClaim removes any claim on a page.
+func (db *DB) ReleaseClaim(pageID int64) error {
+	_, err := db.Exec("DELETE FROM claims WHERE page_id = ?", pageID)
+	return err
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClaims(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := db.CreateUser("bob", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := db.CreatePage("source", "Source", "[[Elves]]", alice.ID, []Link{{TargetSlug: "elves", DisplayText: "Elves"}}, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	phantom, err := db.CreatePhantom("elves", "Elves", alice.ID, source.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.ClaimPage(phantom.ID, alice.ID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ClaimPage(phantom.ID, bob.ID, time.Hour); err != ErrClaimed {
+		t.Errorf("ClaimPage by another user: error = %v, want ErrClaimed", err)
+	}
+	if _, err := db.CreatePage("elves", "Elves", "Pointy ears.", bob.ID, nil, RevisionMeta{}); err != ErrClaimed {
+		t.Errorf("CreatePage over another user's claim: error = %v, want ErrClaimed", err)
+	}
+
+	// An expired claim no longer blocks anyone
+	if _, err := db.Exec("UPDATE claims SET expires_at = ?", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetClaim(phantom.ID); err != ErrNotFound {
+		t.Errorf("GetClaim after expiry: error = %v, want ErrNotFound", err)
+	}
+	if _, err := db.CreatePage("elves", "Elves", "Pointy ears.", bob.ID, nil, RevisionMeta{}); err != nil {
+		t.Errorf("CreatePage after claim expired: %v", err)
+	}
+}
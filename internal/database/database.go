@@ -14,7 +14,9 @@ type DB struct {
 
 // Open creates a new database connection and initializes the schema.
 func Open(path string) (*DB, error) {
-	sqlDB, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)")
+	// Background jobs write alongside requests, so wait for a lock rather
+	// than failing with SQLITE_BUSY.
+	sqlDB, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -37,10 +39,44 @@ func (db *DB) migrate() error {
 		username TEXT UNIQUE NOT NULL,
 		password_hash TEXT NOT NULL,
 		role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('admin', 'user')),
+		feed_token_hash TEXT,
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
 
+	-- Game rounds (at most one open at a time)
+	CREATE TABLE IF NOT EXISTS rounds (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		number INTEGER UNIQUE NOT NULL,
+		label TEXT NOT NULL,
+		deadline DATETIME,
+		status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'open', 'closed')),
+		opened_at DATETIME,
+		closed_at DATETIME,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Players (the in-world scholar persona of each user)
+	CREATE TABLE IF NOT EXISTS players (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER UNIQUE NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		display_name TEXT NOT NULL,
+		bio_slug TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Assignments (the entry each player writes in a round)
+	CREATE TABLE IF NOT EXISTS assignments (
+		player_id INTEGER NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+		round_id INTEGER NOT NULL REFERENCES rounds(id) ON DELETE CASCADE,
+		slug TEXT NOT NULL,
+		title TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (player_id, round_id),
+		UNIQUE (round_id, slug)
+	);
+
 	-- Pages table
 	CREATE TABLE IF NOT EXISTS pages (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -50,6 +86,9 @@ func (db *DB) migrate() error {
 		first_cited_by_user_id INTEGER REFERENCES users(id),
 		first_cited_in_page_id INTEGER REFERENCES pages(id),
 		deleted_at DATETIME,
+		redirect_to TEXT NOT NULL DEFAULT '',
+		round_id INTEGER REFERENCES rounds(id),
+		sealed INTEGER NOT NULL DEFAULT 0,
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
@@ -60,6 +99,10 @@ func (db *DB) migrate() error {
 		page_id INTEGER NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
 		content TEXT NOT NULL,
 		author_id INTEGER NOT NULL REFERENCES users(id),
+		revert_of_id INTEGER REFERENCES revisions(id),
+		summary TEXT NOT NULL DEFAULT '',
+		is_minor INTEGER NOT NULL DEFAULT 0,
+		round_id INTEGER REFERENCES rounds(id),
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
 
@@ -81,6 +124,79 @@ func (db *DB) migrate() error {
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
 
+	-- API tokens (personal tokens for scripts; only a hash of each is kept)
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		token_hash TEXT UNIQUE NOT NULL,
+		scope TEXT NOT NULL CHECK (scope IN ('read', 'write', 'admin')),
+		expires_at DATETIME,
+		last_used_at DATETIME,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Links table (outgoing wiki-links of each page's current revision)
+	CREATE TABLE IF NOT EXISTS links (
+		source_page_id INTEGER NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+		target_slug TEXT NOT NULL,
+		display_text TEXT NOT NULL,
+		revision_id INTEGER NOT NULL REFERENCES revisions(id) ON DELETE CASCADE,
+		PRIMARY KEY (source_page_id, target_slug)
+	);
+
+	-- Redirects table (old slugs of moved pages and aliases)
+	CREATE TABLE IF NOT EXISTS redirects (
+		from_slug TEXT PRIMARY KEY,
+		page_id INTEGER NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+		is_alias INTEGER NOT NULL DEFAULT 0,
+		title TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Claims table (phantoms reserved by the user who will write them)
+	CREATE TABLE IF NOT EXISTS claims (
+		page_id INTEGER PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		claimed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL
+	);
+
+	-- Job runs table (latest run of each background job)
+	CREATE TABLE IF NOT EXISTS job_runs (
+		name TEXT PRIMARY KEY,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME,
+		status TEXT NOT NULL,
+		message TEXT NOT NULL DEFAULT ''
+	);
+
+	-- Webhooks (URLs notified of wiki events; events is a comma-separated list)
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL DEFAULT '',
+		active INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Webhook deliveries (queued notifications, retried with backoff until they
+	-- succeed or run out of attempts)
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at DATETIME,
+		last_attempt_at DATETIME,
+		response_status INTEGER NOT NULL DEFAULT 0,
+		response TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
 	-- Settings table (key-value)
 	CREATE TABLE IF NOT EXISTS settings (
 		key TEXT PRIMARY KEY,
@@ -92,7 +208,13 @@ func (db *DB) migrate() error {
 	CREATE INDEX IF NOT EXISTS idx_pages_deleted_at ON pages(deleted_at);
 	CREATE INDEX IF NOT EXISTS idx_revisions_page_id ON revisions(page_id);
 	CREATE INDEX IF NOT EXISTS idx_comments_page_id ON comments(page_id);
+	CREATE INDEX IF NOT EXISTS idx_links_target_slug ON links(target_slug);
+	CREATE INDEX IF NOT EXISTS idx_redirects_page_id ON redirects(page_id);
+	CREATE INDEX IF NOT EXISTS idx_assignments_slug ON assignments(slug);
 	CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
+	CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
 	`
 
 	if _, err := db.Exec(schema); err != nil {
@@ -118,21 +240,65 @@ func (db *DB) migrate() error {
 }
 
 func (db *DB) runMigrations() error {
-	// Migration: Add deleted_at column to pages table if it doesn't exist
+	// Columns added after the initial schema, applied to existing databases
+	migrations := []struct {
+		table, column, definition string
+	}{
+		{"pages", "deleted_at", "DATETIME"},
+		{"revisions", "revert_of_id", "INTEGER REFERENCES revisions(id)"},
+		{"revisions", "summary", "TEXT NOT NULL DEFAULT ''"},
+		{"revisions", "is_minor", "INTEGER NOT NULL DEFAULT 0"},
+		{"users", "feed_token_hash", "TEXT"},
+		{"pages", "redirect_to", "TEXT NOT NULL DEFAULT ''"},
+		{"redirects", "is_alias", "INTEGER NOT NULL DEFAULT 0"},
+		{"redirects", "title", "TEXT NOT NULL DEFAULT ''"},
+		{"pages", "round_id", "INTEGER REFERENCES rounds(id)"},
+		{"revisions", "round_id", "INTEGER REFERENCES rounds(id)"},
+		{"pages", "sealed", "INTEGER NOT NULL DEFAULT 0"},
+	}
+
+	for _, m := range migrations {
+		if err := db.addColumnIfMissing(m.table, m.column, m.definition); err != nil {
+			return err
+		}
+	}
+
+	if err := db.hashFeedTokens(); err != nil {
+		return fmt.Errorf("failed to hash feed tokens: %w", err)
+	}
+
+	// Indexes on migrated columns
+	indexes := []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_feed_token_hash ON users(feed_token_hash)`,
+		`CREATE INDEX IF NOT EXISTS idx_pages_round_id ON pages(round_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_revisions_round_id ON revisions(round_id)`,
+	}
+	for _, stmt := range indexes {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// addColumnIfMissing adds a column to a table if it doesn't already exist.
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
 	var colCount int
-	err := db.QueryRow(`
-		SELECT COUNT(*) FROM pragma_table_info('pages') WHERE name='deleted_at'
-	`).Scan(&colCount)
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column,
+	).Scan(&colCount)
 	if err != nil {
-		return fmt.Errorf("failed to check for deleted_at column: %w", err)
+		return fmt.Errorf("failed to check for %s.%s column: %w", table, column, err)
 	}
-	if colCount == 0 {
-		_, err = db.Exec(`ALTER TABLE pages ADD COLUMN deleted_at DATETIME`)
-		if err != nil {
-			return fmt.Errorf("failed to add deleted_at column: %w", err)
-		}
+	if colCount > 0 {
+		return nil
 	}
 
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add %s.%s column: %w", table, column, err)
+	}
 	return nil
 }
 
@@ -163,10 +329,18 @@ func (db *DB) createFTS() error {
 
 func (db *DB) ensureDefaultSettings() error {
 	defaults := map[string]string{
-		"public_read_access":   "false",
-		"registration_enabled": "false",
-		"registration_code":    "",
-		"wiki_title":           "Lexicon Wiki",
+		"public_read_access":    "false",
+		"registration_enabled":  "false",
+		"registration_code":     "",
+		"wiki_title":            "Lexicon Wiki",
+		"claim_duration_hours":  "48",
+		"rule_no_self_citation": "false",
+		"rule_min_citations":    "0",
+		"rule_min_phantoms":     "0",
+		"rule_no_own_phantom":   "false",
+		"seal_round_entries":    "false",
+		"backup_interval_hours": "0",
+		"backup_keep":           "7",
 	}
 
 	for key, value := range defaults {
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// HasFeedToken reports whether the user has a feed token.
+func (db *DB) HasFeedToken(userID int64) (bool, error) {
+	var hash sql.NullString
+	err := db.QueryRow("SELECT feed_token_hash FROM users WHERE id = ?", userID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	return hash.Valid && hash.String != "", nil
+}
+
+// ResetFeedToken gives the user a new feed token, invalidating old feed
+// URLs. Only a hash is stored, so the token can't be shown again.
+func (db *DB) ResetFeedToken(userID int64) (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(bytes)
+
+	_, err := db.Exec("UPDATE users SET feed_token_hash = ? WHERE id = ?", hashFeedToken(token), userID)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetUserByFeedToken retrieves the user owning a feed token.
+func (db *DB) GetUserByFeedToken(token string) (*User, error) {
+	if token == "" {
+		return nil, ErrNotFound
+	}
+	var id int64
+	err := db.QueryRow("SELECT id FROM users WHERE feed_token_hash = ?", hashFeedToken(token)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return db.GetUserByID(id)
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFeedTokens replaces feed tokens stored in plain text by older
+// versions with their hashes, so existing feed URLs keep working.
+func (db *DB) hashFeedTokens() error {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('users') WHERE name = 'feed_token'").Scan(&n)
+	if err != nil || n == 0 {
+		return err
+	}
+
+	rows, err := db.Query("SELECT id, feed_token FROM users WHERE feed_token IS NOT NULL AND feed_token != ''")
+	if err != nil {
+		return err
+	}
+	tokens := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var token string
+		if err := rows.Scan(&id, &token); err != nil {
+			rows.Close()
+			return err
+		}
+		tokens[id] = token
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, token := range tokens {
+		_, err := db.Exec("UPDATE users SET feed_token_hash = ?, feed_token = NULL WHERE id = ?", hashFeedToken(token), id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListNewPages returns the first revision of recently created non-deleted
+// pages, newest first.
+func (db *DB) ListNewPages(limit int) ([]*RecentEdit, error) {
+	rows, err := db.Query(`
+		SELECT `+revisionColumns+`, p.slug, p.title
+		FROM revisions r
+		JOIN users u ON r.author_id = u.id
+		JOIN pages p ON r.page_id = p.id
+		WHERE p.is_phantom = 0 AND p.deleted_at IS NULL AND p.sealed = 0
+			AND r.id = (SELECT MIN(first.id) FROM revisions first WHERE first.page_id = r.page_id)
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRecentEdits(rows)
+}
+
+// ListRecentPhantoms returns the most recently cited phantoms with their
+// source page info, newest first. Phantoms first cited in a sealed entry are
+// left out until it's published.
+func (db *DB) ListRecentPhantoms(limit int) ([]*PhantomWithSource, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.slug, p.title, p.is_phantom, p.first_cited_by_user_id, p.first_cited_in_page_id, p.deleted_at, p.redirect_to, p.round_id, p.sealed, p.created_at, p.updated_at,
+		       COALESCE(src.slug, '') as source_slug, COALESCE(src.title, '') as source_title,
+		       COALESCE(cu.username, '') as claimed_by, c.expires_at
+		FROM pages p
+		LEFT JOIN pages src ON p.first_cited_in_page_id = src.id
+		LEFT JOIN claims c ON c.page_id = p.id AND c.expires_at > ?
+		LEFT JOIN users cu ON c.user_id = cu.id
+		WHERE p.is_phantom = 1 AND p.deleted_at IS NULL AND (src.id IS NULL OR src.sealed = 0)
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT ?
+	`, time.Now(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPhantomsWithSource(rows)
+}
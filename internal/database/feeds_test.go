@@ -0,0 +1,124 @@
+package database
+
+import "testing"
+
+func TestFeedTokens(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has, err := db.HasFeedToken(alice.ID); err != nil || has {
+		t.Fatalf("HasFeedToken = %v, %v; want false before a reset", has, err)
+	}
+
+	token, err := db.ResetFeedToken(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := db.HasFeedToken(alice.ID); !has {
+		t.Error("HasFeedToken = false after a reset")
+	}
+
+	// Only the hash is stored
+	var stored string
+	if err := db.QueryRow("SELECT feed_token_hash FROM users WHERE id = ?", alice.ID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored == token {
+		t.Error("feed token is stored in the clear")
+	}
+
+	if user, err := db.GetUserByFeedToken(token); err != nil || user.ID != alice.ID {
+		t.Errorf("GetUserByFeedToken = %v, %v; want alice", user, err)
+	}
+	if _, err := db.GetUserByFeedToken(stored); err != ErrNotFound {
+		t.Errorf("GetUserByFeedToken(hash) error = %v, want ErrNotFound", err)
+	}
+
+	// Resetting invalidates the old token
+	if _, err := db.ResetFeedToken(alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetUserByFeedToken(token); err != ErrNotFound {
+		t.Errorf("old token error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestHashFeedTokens(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Older versions stored feed tokens as is
+	if _, err := db.Exec("ALTER TABLE users ADD COLUMN feed_token TEXT"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE users SET feed_token = 'plain' WHERE id = ?", alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.hashFeedTokens(); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := db.GetUserByFeedToken("plain"); err != nil || user.ID != alice.ID {
+		t.Errorf("GetUserByFeedToken = %v, %v; want alice's old token to keep working", user, err)
+	}
+	var plain *string
+	if err := db.QueryRow("SELECT feed_token FROM users WHERE id = ?", alice.ID).Scan(&plain); err != nil {
+		t.Fatal(err)
+	}
+	if plain != nil {
+		t.Errorf("plain text token %q left behind", *plain)
+	}
+}
+
+func TestListRecentPhantomsSealed(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSetting("seal_round_entries", "true"); err != nil {
+		t.Fatal(err)
+	}
+	round, err := db.CreateRound("A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.OpenRound(round.ID); err != nil {
+		t.Fatal(err)
+	}
+	links := []Link{{TargetSlug: "burrows", DisplayText: "Burrows"}}
+	page, err := db.CreatePage("aardvarks", "Aardvarks", "They dig [[Burrows]].", alice.ID, links, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePhantom("burrows", "Burrows", alice.ID, page.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	phantoms, err := db.ListRecentPhantoms(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(phantoms) != 0 {
+		t.Errorf("got %d phantoms, want none cited by a sealed entry", len(phantoms))
+	}
+
+	if _, err := db.PublishRound(round.ID); err != nil {
+		t.Fatal(err)
+	}
+	phantoms, err = db.ListRecentPhantoms(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(phantoms) != 1 || phantoms[0].SourceSlug != "aardvarks" {
+		t.Errorf("after publishing, phantoms = %+v, want burrows cited in aardvarks", phantoms)
+	}
+}
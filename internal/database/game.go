@@ -0,0 +1,297 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Round statuses.
+const (
+	RoundPending = "pending"
+	RoundOpen    = "open"
+	RoundClosed  = "closed"
+)
+
+// ErrRoundInUse is returned when deleting a round that entries were written in.
+var ErrRoundInUse = errors.New("round has entries")
+
+// openRoundID is a subquery selecting the open round, used to tag new pages
+// and revisions.
+const openRoundID = `(SELECT id FROM rounds WHERE status = 'open' LIMIT 1)`
+
+// sealNewEntry is an expression deciding whether an entry written now starts
+// sealed: only while a round is open and sealing is enabled.
+const sealNewEntry = `(EXISTS (SELECT 1 FROM rounds WHERE status = 'open')
+	AND EXISTS (SELECT 1 FROM settings WHERE key = 'seal_round_entries' AND value = 'true'))`
+
+// Round is one turn of a Lexicon game, usually tied to a letter.
+type Round struct {
+	ID        int64
+	Number    int
+	Label     string
+	Deadline  *time.Time
+	Status    string
+	OpenedAt  *time.Time
+	ClosedAt  *time.Time
+	CreatedAt time.Time
+
+	// Joined fields (not always populated)
+	EntryCount  int
+	SealedCount int
+}
+
+// IsOpen reports whether entries are currently being written in the round.
+func (r *Round) IsOpen() bool {
+	return r.Status == RoundOpen
+}
+
+// IsOverdue reports whether an open round is past its deadline.
+func (r *Round) IsOverdue() bool {
+	return r.IsOpen() && r.Deadline != nil && time.Now().After(*r.Deadline)
+}
+
+const roundColumns = `r.id, r.number, r.label, r.deadline, r.status, r.opened_at, r.closed_at, r.created_at,
+	(SELECT COUNT(*) FROM pages p WHERE p.round_id = r.id AND p.is_phantom = 0 AND p.deleted_at IS NULL),
+	(SELECT COUNT(*) FROM pages p WHERE p.round_id = r.id AND p.sealed = 1)`
+
+func scanRound(row rowScanner) (*Round, error) {
+	round := &Round{}
+	err := row.Scan(
+		&round.ID, &round.Number, &round.Label, &round.Deadline, &round.Status,
+		&round.OpenedAt, &round.ClosedAt, &round.CreatedAt, &round.EntryCount, &round.SealedCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return round, nil
+}
+
+// CreateRound appends a pending round after the existing ones.
+func (db *DB) CreateRound(label string, deadline *time.Time) (*Round, error) {
+	result, err := db.Exec(`
+		INSERT INTO rounds (number, label, deadline, status, created_at)
+		VALUES ((SELECT COALESCE(MAX(number), 0) + 1 FROM rounds), ?, ?, 'pending', ?)
+	`, label, deadline, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return db.GetRound(id)
+}
+
+// GetRound retrieves a round by ID.
+func (db *DB) GetRound(id int64) (*Round, error) {
+	return scanRound(db.QueryRow(`SELECT `+roundColumns+` FROM rounds r WHERE r.id = ?`, id))
+}
+
+// CurrentRound returns the open round, or ErrNotFound between rounds.
+func (db *DB) CurrentRound() (*Round, error) {
+	return scanRound(db.QueryRow(`SELECT ` + roundColumns + ` FROM rounds r WHERE r.status = 'open' LIMIT 1`))
+}
+
+// NextRound returns the first pending round, or ErrNotFound if none is left.
+func (db *DB) NextRound() (*Round, error) {
+	return scanRound(db.QueryRow(`
+		SELECT ` + roundColumns + ` FROM rounds r WHERE r.status = 'pending' ORDER BY r.number LIMIT 1
+	`))
+}
+
+// ListRounds returns all rounds in play order.
+func (db *DB) ListRounds() ([]*Round, error) {
+	rows, err := db.Query(`SELECT ` + roundColumns + ` FROM rounds r ORDER BY r.number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rounds []*Round
+	for rows.Next() {
+		round, err := scanRound(rows)
+		if err != nil {
+			return nil, err
+		}
+		rounds = append(rounds, round)
+	}
+	return rounds, rows.Err()
+}
+
+// UpdateRound changes a round's label and deadline.
+func (db *DB) UpdateRound(id int64, label string, deadline *time.Time) error {
+	_, err := db.Exec("UPDATE rounds SET label = ?, deadline = ? WHERE id = ?", label, deadline, id)
+	return err
+}
+
+// OpenRound makes a round the current one, closing any other open round and
+// publishing its sealed entries. It returns the IDs of the pages published.
+func (db *DB) OpenRound(id int64) ([]int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	published, err := unseal(tx, "round_id IN (SELECT id FROM rounds WHERE status = 'open' AND id != ?)", id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE rounds SET status = 'closed', closed_at = ? WHERE status = 'open' AND id != ?
+	`, now, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE rounds SET status = 'open', opened_at = COALESCE(opened_at, ?), closed_at = NULL WHERE id = ?
+	`, now, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// CloseRound ends a round and publishes its sealed entries, returning the
+// IDs of the pages published. Edits made afterwards are not tagged with any
+// round until the next one opens.
+func (db *DB) CloseRound(id int64) ([]int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE rounds SET status = 'closed', closed_at = ? WHERE id = ? AND status = 'open'
+	`, time.Now(), id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+
+	published, err := unseal(tx, "round_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// PublishRound unseals the entries written in a round and returns the IDs
+// of the pages published.
+func (db *DB) PublishRound(id int64) ([]int64, error) {
+	return unseal(db, "round_id = ?", id)
+}
+
+// PublishDueRounds unseals the entries of rounds whose deadline has passed
+// and returns the IDs of the pages published.
+func (db *DB) PublishDueRounds() ([]int64, error) {
+	return unseal(db, "round_id IN (SELECT id FROM rounds WHERE deadline IS NOT NULL AND deadline <= ?)", time.Now())
+}
+
+// queryer is a *DB or a *sql.Tx.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// unseal publishes the sealed pages matching where and returns their IDs.
+func unseal(q queryer, where string, args ...any) ([]int64, error) {
+	rows, err := q.Query("UPDATE pages SET sealed = 0 WHERE sealed = 1 AND "+where+" RETURNING id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CanViewSealed reports whether a user may see a sealed page: its author
+// can, as can admins.
+func (db *DB) CanViewSealed(page *Page, user *User) bool {
+	if !page.Sealed {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	if user.IsAdmin() {
+		return true
+	}
+	author, err := db.PageAuthor(page.ID)
+	return err == nil && author == user.ID
+}
+
+// DeleteRound removes a round that no page or revision is tagged with.
+func (db *DB) DeleteRound(id int64) error {
+	var used int
+	err := db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM pages WHERE round_id = ?) + (SELECT COUNT(*) FROM revisions WHERE round_id = ?)
+	`, id, id).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used > 0 {
+		return ErrRoundInUse
+	}
+
+	_, err = db.Exec("DELETE FROM rounds WHERE id = ?", id)
+	return err
+}
+
+// ListRoundPages returns the non-deleted entries written in a round.
+func (db *DB) ListRoundPages(roundID int64) ([]*Page, error) {
+	rows, err := db.Query(`
+		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, redirect_to, round_id, sealed, created_at, updated_at
+		FROM pages WHERE round_id = ? AND is_phantom = 0 AND deleted_at IS NULL ORDER BY title ASC
+	`, roundID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPages(rows)
+}
+
+// ListRoundRevisions returns the revisions saved during a round, oldest first.
+func (db *DB) ListRoundRevisions(roundID int64) ([]*RecentEdit, error) {
+	rows, err := db.Query(`
+		SELECT `+revisionColumns+`, p.slug, p.title
+		FROM revisions r
+		JOIN users u ON r.author_id = u.id
+		JOIN pages p ON r.page_id = p.id
+		WHERE r.round_id = ?
+		ORDER BY r.created_at ASC, r.id ASC
+	`, roundID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRecentEdits(rows)
+}
@@ -0,0 +1,186 @@
+package database
+
+import "testing"
+
+func TestRounds(t *testing.T) {
+	db := openTestDB(t)
+
+	user, err := db.CreateUser("testuser", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := db.CreateRound("A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := db.CreateRound("B", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Number != 1 || b.Number != 2 {
+		t.Errorf("round numbers = %d, %d; want 1, 2", a.Number, b.Number)
+	}
+
+	// Nothing is tagged between rounds
+	before, err := db.CreatePage("prologue", "Prologue", "Before the game.", user.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.RoundID != nil {
+		t.Errorf("page written before any round has round %d", *before.RoundID)
+	}
+
+	if _, err := db.OpenRound(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	apple, err := db.CreatePage("apple", "Apple", "A fruit.", user.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apple.RoundID == nil || *apple.RoundID != a.ID {
+		t.Errorf("apple round = %v, want %d", apple.RoundID, a.ID)
+	}
+
+	// Opening the next round closes the current one
+	if _, err := db.OpenRound(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	current, err := db.CurrentRound()
+	if err != nil || current.ID != b.ID {
+		t.Fatalf("current round = %+v, %v; want B", current, err)
+	}
+	if a, _ = db.GetRound(a.ID); a.Status != RoundClosed || a.EntryCount != 1 {
+		t.Errorf("round A = %+v, want closed with 1 entry", a)
+	}
+
+	// Later edits are tagged with the round they were made in, but the
+	// entry keeps the round it was written in
+	if err := db.UpdatePage(apple.ID, "Apple", "A red fruit.", user.ID, nil, RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	rev, err := db.GetCurrentRevision(apple.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.RoundID == nil || *rev.RoundID != b.ID {
+		t.Errorf("revision round = %v, want %d", rev.RoundID, b.ID)
+	}
+	if apple, _ = db.GetPageByID(apple.ID); *apple.RoundID != a.ID {
+		t.Errorf("apple moved to round %d", *apple.RoundID)
+	}
+
+	if err := db.DeleteRound(a.ID); err != ErrRoundInUse {
+		t.Errorf("DeleteRound(A) err = %v, want ErrRoundInUse", err)
+	}
+}
+
+func TestSealedEntries(t *testing.T) {
+	db := openTestDB(t)
+
+	user, err := db.CreateUser("testuser", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSetting("seal_round_entries", "true"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries written between rounds are never sealed
+	prologue, err := db.CreatePage("prologue", "Prologue", "Before the game.", user.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prologue.Sealed {
+		t.Error("entry written between rounds is sealed")
+	}
+
+	round, err := db.CreateRound("A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.OpenRound(round.ID); err != nil {
+		t.Fatal(err)
+	}
+	page, err := db.CreatePage("aardvarks", "Aardvarks", "Burrowing mammals.", user.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !page.Sealed {
+		t.Fatal("entry written during an open round is not sealed")
+	}
+
+	if pages, _ := db.ListPages(); len(pages) != 1 {
+		t.Errorf("ListPages returned %d pages, want only the unsealed one", len(pages))
+	}
+	if results, _ := db.Search("burrowing", 10); len(results) != 0 {
+		t.Errorf("Search found %d sealed pages, want none", len(results))
+	}
+
+	published, err := db.PublishRound(round.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 1 || published[0] != page.ID {
+		t.Errorf("PublishRound = %v, want [%d]", published, page.ID)
+	}
+	if results, _ := db.Search("burrowing", 10); len(results) != 1 {
+		t.Errorf("Search after publishing found %d pages, want 1", len(results))
+	}
+}
+
+func TestRoundEndPublishes(t *testing.T) {
+	db := openTestDB(t)
+
+	user, err := db.CreateUser("testuser", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSetting("seal_round_entries", "true"); err != nil {
+		t.Fatal(err)
+	}
+
+	var rounds []*Round
+	for _, label := range []string{"A", "B"} {
+		round, err := db.CreateRound(label, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rounds = append(rounds, round)
+	}
+
+	// Opening the next round publishes the entries of one with no deadline
+	if _, err := db.OpenRound(rounds[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	apple, err := db.CreatePage("apple", "Apple", "A fruit.", user.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := db.OpenRound(rounds[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 1 || published[0] != apple.ID {
+		t.Errorf("OpenRound published %v, want [%d]", published, apple.ID)
+	}
+	if apple, _ = db.GetPageByID(apple.ID); apple.Sealed {
+		t.Error("entry of the previous round is still sealed")
+	}
+
+	// So does closing it
+	banana, err := db.CreatePage("banana", "Banana", "Another fruit.", user.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err = db.CloseRound(rounds[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 1 || published[0] != banana.ID {
+		t.Errorf("CloseRound published %v, want [%d]", published, banana.ID)
+	}
+	if _, err := db.CloseRound(rounds[1].ID); err != ErrNotFound {
+		t.Errorf("closing a closed round: err = %v, want ErrNotFound", err)
+	}
+}
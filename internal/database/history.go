@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// HistoryVisitor receives every record in the wiki, one kind after another
+// in this field order, so records only refer to ones visited before them.
+// Nil callbacks skip their kind.
+type HistoryVisitor struct {
+	Setting  func(key, value string) error
+	User     func(*User) error
+	Round    func(*Round) error
+	Page     func(*Page) error // including phantoms, deleted and sealed pages
+	Redirect func(*Redirect) error
+	Revision func(*Revision) error // every revision, oldest first
+	Comment  func(*Comment) error
+}
+
+// WalkHistory streams the whole wiki to v from a single read transaction, so
+// the records are consistent with each other without holding them all in
+// memory.
+func (db *DB) WalkHistory(ctx context.Context, v HistoryVisitor) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if v.Setting != nil {
+		err := walk(tx, `SELECT key, value FROM settings ORDER BY key`, func(rows *sql.Rows) error {
+			var key, value string
+			if err := rows.Scan(&key, &value); err != nil {
+				return err
+			}
+			return v.Setting(key, value)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if v.User != nil {
+		err := walk(tx, `
+			SELECT id, username, password_hash, role, created_at, updated_at FROM users ORDER BY id
+		`, func(rows *sql.Rows) error {
+			user := &User{}
+			if err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.UpdatedAt); err != nil {
+				return err
+			}
+			return v.User(user)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if v.Round != nil {
+		err := walk(tx, `SELECT `+roundColumns+` FROM rounds r ORDER BY r.number`, func(rows *sql.Rows) error {
+			round, err := scanRound(rows)
+			if err != nil {
+				return err
+			}
+			return v.Round(round)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if v.Page != nil {
+		err := walk(tx, `
+			SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, redirect_to, round_id, sealed, created_at, updated_at
+			FROM pages ORDER BY id
+		`, func(rows *sql.Rows) error {
+			page := &Page{}
+			err := rows.Scan(
+				&page.ID, &page.Slug, &page.Title, &page.IsPhantom,
+				&page.FirstCitedByUserID, &page.FirstCitedInPageID,
+				&page.DeletedAt, &page.RedirectTo, &page.RoundID, &page.Sealed, &page.CreatedAt, &page.UpdatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			return v.Page(page)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if v.Redirect != nil {
+		err := walk(tx, `
+			SELECT from_slug, page_id, is_alias, title, created_at FROM redirects ORDER BY created_at, from_slug
+		`, func(rows *sql.Rows) error {
+			r := &Redirect{}
+			if err := rows.Scan(&r.FromSlug, &r.PageID, &r.IsAlias, &r.Title, &r.CreatedAt); err != nil {
+				return err
+			}
+			return v.Redirect(r)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if v.Revision != nil {
+		err := walk(tx, `
+			SELECT `+revisionColumns+`
+			FROM revisions r
+			JOIN users u ON r.author_id = u.id
+			ORDER BY r.id
+		`, func(rows *sql.Rows) error {
+			rev, err := scanRevision(rows)
+			if err != nil {
+				return err
+			}
+			return v.Revision(rev)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if v.Comment != nil {
+		err := walk(tx, `
+			SELECT c.id, c.page_id, c.author_id, c.content, c.created_at, c.updated_at, u.username
+			FROM comments c
+			JOIN users u ON c.author_id = u.id
+			ORDER BY c.id
+		`, func(rows *sql.Rows) error {
+			c := &Comment{}
+			if err := rows.Scan(&c.ID, &c.PageID, &c.AuthorID, &c.Content, &c.CreatedAt, &c.UpdatedAt, &c.AuthorUsername); err != nil {
+				return err
+			}
+			return v.Comment(c)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walk calls fn for each row of a query.
+func walk(tx *sql.Tx, query string, fn func(rows *sql.Rows) error) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Job run statuses.
+const (
+	JobRunning = "running"
+	JobOK      = "ok"
+	JobFailed  = "failed"
+)
+
+// JobRun is the persisted state of a background job's latest run.
+type JobRun struct {
+	Name       string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	Status     string
+	Message    string
+}
+
+// Duration returns how long the run took, or zero while it is running.
+func (r *JobRun) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// GetJobRun returns the latest run of a job, or ErrNotFound if it never ran.
+func (db *DB) GetJobRun(name string) (*JobRun, error) {
+	run := &JobRun{}
+	err := db.QueryRow(`
+		SELECT name, started_at, finished_at, status, message FROM job_runs WHERE name = ?
+	`, name).Scan(&run.Name, &run.StartedAt, &run.FinishedAt, &run.Status, &run.Message)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// StartJobRun records that a job has started.
+func (db *DB) StartJobRun(name string, startedAt time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO job_runs (name, started_at, finished_at, status, message) VALUES (?, ?, NULL, 'running', '')
+		ON CONFLICT (name) DO UPDATE SET
+			started_at = excluded.started_at, finished_at = NULL, status = 'running', message = ''
+	`, name, startedAt)
+	return err
+}
+
+// FinishJobRun records the outcome of a job's latest run.
+func (db *DB) FinishJobRun(name, status, message string) error {
+	_, err := db.Exec(`
+		UPDATE job_runs SET finished_at = ?, status = ?, message = ? WHERE name = ?
+	`, time.Now(), status, message, name)
+	return err
+}
+
+// OptimizeIndexes merges the search index segments and refreshes the query
+// planner statistics.
+func (db *DB) OptimizeIndexes() error {
+	if _, err := db.Exec("INSERT INTO pages_fts (pages_fts) VALUES ('optimize')"); err != nil {
+		return err
+	}
+	_, err := db.Exec("PRAGMA optimize")
+	return err
+}
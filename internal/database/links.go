@@ -0,0 +1,265 @@
+package database
+
+import (
+	"database/sql"
+)
+
+// Link represents an outgoing wiki-link from a page.
+type Link struct {
+	TargetSlug  string
+	DisplayText string
+}
+
+// Backlink represents a page that links to a given slug.
+type Backlink struct {
+	*Page
+	DisplayText string
+	RevisionID  int64
+}
+
+// replaceLinks updates the stored links of a page to match the given set and
+// returns the target slugs that are no longer linked.
+// Links that are still present keep the revision that introduced them.
+func replaceLinks(tx *sql.Tx, pageID, revisionID int64, links []Link) ([]string, error) {
+	wanted := make(map[string]bool)
+	var unique []Link
+	for _, link := range links {
+		if link.TargetSlug == "" || wanted[link.TargetSlug] {
+			continue
+		}
+		wanted[link.TargetSlug] = true
+		unique = append(unique, link)
+	}
+
+	rows, err := tx.Query("SELECT target_slug FROM links WHERE source_page_id = ?", pageID)
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !wanted[slug] {
+			stale = append(stale, slug)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, slug := range stale {
+		_, err := tx.Exec("DELETE FROM links WHERE source_page_id = ? AND target_slug = ?", pageID, slug)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, link := range unique {
+		_, err := tx.Exec(`
+			INSERT INTO links (source_page_id, target_slug, display_text, revision_id)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(source_page_id, target_slug) DO UPDATE SET display_text = excluded.display_text
+		`, pageID, link.TargetSlug, link.DisplayText, revisionID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stale, nil
+}
+
+// reconcilePhantoms updates phantoms that the given page stopped citing.
+// A phantom that is no longer cited by any page is retired. If the page was
+// credited with the first citation, credit moves to the earliest remaining one.
+func reconcilePhantoms(tx *sql.Tx, pageID int64, slugs []string) error {
+	for _, slug := range slugs {
+		var phantomID int64
+		var citedInPageID sql.NullInt64
+		err := tx.QueryRow(`
+			SELECT id, first_cited_in_page_id FROM pages WHERE slug = ? AND is_phantom = 1
+		`, slug).Scan(&phantomID, &citedInPageID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var sourcePageID, authorID int64
+		err = tx.QueryRow(`
+			SELECT l.source_page_id, r.author_id
+			FROM links l
+			JOIN revisions r ON l.revision_id = r.id
+			WHERE l.target_slug = ?
+			ORDER BY l.revision_id ASC
+			LIMIT 1
+		`, slug).Scan(&sourcePageID, &authorID)
+		if err == sql.ErrNoRows {
+			if _, err := tx.Exec("DELETE FROM pages WHERE id = ?", phantomID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if !citedInPageID.Valid || citedInPageID.Int64 == pageID {
+			_, err = tx.Exec(`
+				UPDATE pages SET first_cited_by_user_id = ?, first_cited_in_page_id = ? WHERE id = ?
+			`, authorID, sourcePageID, phantomID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RetireOrphanedPhantoms removes phantoms that no page links to anymore and
+// returns how many were removed.
+func (db *DB) RetireOrphanedPhantoms() (int64, error) {
+	result, err := db.Exec(`
+		DELETE FROM pages
+		WHERE is_phantom = 1
+		  AND NOT EXISTS (SELECT 1 FROM links WHERE links.target_slug = pages.slug)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListBacklinks returns non-deleted, unsealed pages whose current revision links to the
+// slug, to one of its aliases or former slugs, or to a page redirecting to it.
+func (db *DB) ListBacklinks(slug string) ([]*Backlink, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.slug, p.title, p.is_phantom, p.first_cited_by_user_id, p.first_cited_in_page_id, p.deleted_at, p.redirect_to, p.round_id, p.sealed, p.created_at, p.updated_at,
+		       l.display_text, l.revision_id
+		FROM links l
+		JOIN pages p ON l.source_page_id = p.id
+		WHERE p.deleted_at IS NULL AND p.sealed = 0 AND (l.target_slug = ? OR l.target_slug IN (
+			SELECT r.from_slug FROM redirects r JOIN pages t ON r.page_id = t.id WHERE t.slug = ?
+			UNION
+			SELECT rp.slug FROM pages rp WHERE rp.redirect_to = ? AND rp.deleted_at IS NULL
+		))
+		GROUP BY p.id
+		ORDER BY p.title ASC
+	`, slug, slug, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var backlinks []*Backlink
+	for rows.Next() {
+		page := &Page{}
+		backlink := &Backlink{Page: page}
+		err := rows.Scan(
+			&page.ID, &page.Slug, &page.Title, &page.IsPhantom,
+			&page.FirstCitedByUserID, &page.FirstCitedInPageID,
+			&page.DeletedAt, &page.RedirectTo, &page.RoundID, &page.Sealed, &page.CreatedAt, &page.UpdatedAt,
+			&backlink.DisplayText, &backlink.RevisionID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		backlinks = append(backlinks, backlink)
+	}
+	return backlinks, rows.Err()
+}
+
+// ListLinks returns the outgoing links stored for a page.
+func (db *DB) ListLinks(pageID int64) ([]Link, error) {
+	rows, err := db.Query(`
+		SELECT target_slug, display_text FROM links WHERE source_page_id = ? ORDER BY target_slug ASC
+	`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []Link
+	for rows.Next() {
+		var link Link
+		if err := rows.Scan(&link.TargetSlug, &link.DisplayText); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// NeedsLinkRebuild returns true if revisions contain wiki-links but the link
+// table is empty, as happens for databases created before links were tracked.
+func (db *DB) NeedsLinkRebuild() (bool, error) {
+	var hasLinks, hasWikiLinks bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM links)").Scan(&hasLinks)
+	if err != nil {
+		return false, err
+	}
+	if hasLinks {
+		return false, nil
+	}
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM revisions WHERE content LIKE '%[[%')").Scan(&hasWikiLinks)
+	if err != nil {
+		return false, err
+	}
+	return hasWikiLinks, nil
+}
+
+// RebuildLinks recomputes the link table from the current revision of every
+// page that isn't deleted. The extract function parses revision content into
+// links.
+func (db *DB) RebuildLinks(extract func(content string) []Link) error {
+	rows, err := db.Query(`
+		SELECT r.page_id, r.id, r.content
+		FROM revisions r
+		JOIN pages p ON r.page_id = p.id
+		WHERE p.deleted_at IS NULL
+			AND r.id = (SELECT id FROM revisions WHERE page_id = r.page_id ORDER BY created_at DESC, id DESC LIMIT 1)
+	`)
+	if err != nil {
+		return err
+	}
+
+	type current struct {
+		pageID     int64
+		revisionID int64
+		content    string
+	}
+	var revisions []current
+	for rows.Next() {
+		var c current
+		if err := rows.Scan(&c.pageID, &c.revisionID, &c.content); err != nil {
+			rows.Close()
+			return err
+		}
+		revisions = append(revisions, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM links"); err != nil {
+		return err
+	}
+	for _, c := range revisions {
+		if _, err := replaceLinks(tx, c.pageID, c.revisionID, extract(c.content)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := Open(filepath.Join(t.TempDir(), "lexicon-test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func backlinkSlugs(t *testing.T, db *DB, slug string) []string {
+	t.Helper()
+
+	backlinks, err := db.ListBacklinks(slug)
+	if err != nil {
+		t.Fatalf("ListBacklinks(%q) error: %v", slug, err)
+	}
+	var slugs []string
+	for _, b := range backlinks {
+		slugs = append(slugs, b.Slug)
+	}
+	return slugs
+}
+
+func TestLinks(t *testing.T) {
+	db := openTestDB(t)
+
+	user, err := db.CreateUser("testuser", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.CreatePage("dragons", "Dragons", "See [[The War]].", user.ID, []Link{
+		{TargetSlug: "the-war", DisplayText: "The War"},
+	}, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	war, err := db.CreatePage("the-war", "The War", "Fought by [[Dragons]] and [[Elves]].", user.ID, []Link{
+		{TargetSlug: "dragons", DisplayText: "Dragons"},
+		{TargetSlug: "elves", DisplayText: "Elves"},
+		{TargetSlug: "elves", DisplayText: "elven kind"},
+	}, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := backlinkSlugs(t, db, "the-war"); len(got) != 1 || got[0] != "dragons" {
+		t.Errorf("backlinks of the-war = %v, want [dragons]", got)
+	}
+	if got := backlinkSlugs(t, db, "elves"); len(got) != 1 || got[0] != "the-war" {
+		t.Errorf("backlinks of elves = %v, want [the-war]", got)
+	}
+
+	links, err := db.ListLinks(war.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 {
+		t.Errorf("ListLinks returned %d links, want 2 (duplicates collapsed)", len(links))
+	}
+
+	// Dropping a link removes it from the graph
+	err = db.UpdatePage(war.ID, "The War", "Fought by [[Dragons]].", user.ID, []Link{
+		{TargetSlug: "dragons", DisplayText: "Dragons"},
+	}, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := backlinkSlugs(t, db, "elves"); len(got) != 0 {
+		t.Errorf("backlinks of elves after edit = %v, want none", got)
+	}
+	if got := backlinkSlugs(t, db, "dragons"); len(got) != 1 {
+		t.Errorf("backlinks of dragons after edit = %v, want [the-war]", got)
+	}
+
+	// Rebuilding from content reproduces the graph
+	if err := db.RebuildLinks(func(content string) []Link {
+		if content == "See [[The War]]." {
+			return []Link{{TargetSlug: "the-war", DisplayText: "The War"}}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := backlinkSlugs(t, db, "the-war"); len(got) != 1 {
+		t.Errorf("backlinks of the-war after rebuild = %v, want [dragons]", got)
+	}
+	if got := backlinkSlugs(t, db, "dragons"); len(got) != 0 {
+		t.Errorf("backlinks of dragons after rebuild = %v, want none", got)
+	}
+}
+
+func TestPhantomLifecycle(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := db.CreateUser("bob", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	elves := []Link{{TargetSlug: "elves", DisplayText: "Elves"}}
+	first, err := db.CreatePage("first", "First", "[[Elves]]", alice.ID, elves, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePhantom("elves", "Elves", alice.ID, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	second, err := db.CreatePage("second", "Second", "[[Elves]]", bob.ID, elves, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first citing page drops the link: credit moves to the second page
+	if err := db.UpdatePage(first.ID, "First", "No elves here.", alice.ID, nil, RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	phantom, err := db.GetPageBySlug("elves")
+	if err != nil {
+		t.Fatalf("phantom retired while still cited: %v", err)
+	}
+	if phantom.FirstCitedInPageID == nil || *phantom.FirstCitedInPageID != second.ID {
+		t.Errorf("FirstCitedInPageID = %v, want %d", phantom.FirstCitedInPageID, second.ID)
+	}
+	if phantom.FirstCitedByUserID == nil || *phantom.FirstCitedByUserID != bob.ID {
+		t.Errorf("FirstCitedByUserID = %v, want %d", phantom.FirstCitedByUserID, bob.ID)
+	}
+
+	// The last citation is removed: the phantom is retired
+	if err := db.UpdatePage(second.ID, "Second", "No elves here either.", bob.ID, nil, RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetPageBySlug("elves"); err != ErrNotFound {
+		t.Errorf("GetPageBySlug(elves) error = %v, want ErrNotFound", err)
+	}
+
+	// Sweeping removes phantoms that were never backed by a link
+	if _, err := db.CreatePhantom("typo", "Typo", alice.ID, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	retired, err := db.RetireOrphanedPhantoms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retired != 1 {
+		t.Errorf("RetireOrphanedPhantoms() = %d, want 1", retired)
+	}
+}
+
+func TestDeleteRestoreLinks(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := db.CreateUser("bob", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links := []Link{{TargetSlug: "elves", DisplayText: "Elves"}, {TargetSlug: "dwarves", DisplayText: "Dwarves"}}
+	first, err := db.CreatePage("first", "First", "[[Elves]] and [[Dwarves]]", alice.ID, links, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range links {
+		if _, err := db.CreatePhantom(l.TargetSlug, l.DisplayText, alice.ID, first.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	second, err := db.CreatePage("second", "Second", "[[Elves]]", bob.ID, links[:1], RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting the first citing page moves credit for elves to the second
+	// and retires dwarves, which nothing else cites
+	if err := db.SoftDeletePage(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	elves, err := db.GetPageBySlug("elves")
+	if err != nil {
+		t.Fatalf("phantom retired while still cited: %v", err)
+	}
+	if elves.FirstCitedInPageID == nil || *elves.FirstCitedInPageID != second.ID {
+		t.Errorf("FirstCitedInPageID = %v, want %d", elves.FirstCitedInPageID, second.ID)
+	}
+	if _, err := db.GetPageBySlug("dwarves"); err != ErrNotFound {
+		t.Errorf("GetPageBySlug(dwarves) error = %v, want ErrNotFound", err)
+	}
+
+	// Restoring puts the links back
+	if err := db.RestorePage(first.ID, links); err != nil {
+		t.Fatal(err)
+	}
+	backlinks, err := db.ListBacklinks("dwarves")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backlinks) != 1 || backlinks[0].ID != first.ID {
+		t.Errorf("backlinks of dwarves = %+v, want first", backlinks)
+	}
+	if err := db.RestorePage(first.ID, links); err != ErrNotFound {
+		t.Errorf("restoring twice: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRebuildLinksSkipsDeleted(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := []Link{{TargetSlug: "dwarves", DisplayText: "Dwarves"}}
+	page, err := db.CreatePage("first", "First", "[[Dwarves]]", alice.ID, links, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SoftDeletePage(page.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A phantom left behind that only the deleted page cites
+	if _, err := db.CreatePhantom("dwarves", "Dwarves", alice.ID, page.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.RebuildLinks(func(content string) []Link { return links }); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := db.ListLinks(page.ID); err != nil || len(got) != 0 {
+		t.Errorf("links of the deleted page after rebuild = %v, %v; want none", got, err)
+	}
+	retired, err := db.RetireOrphanedPhantoms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retired != 1 {
+		t.Errorf("RetireOrphanedPhantoms() = %d, want 1", retired)
+	}
+}
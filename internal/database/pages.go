@@ -12,7 +12,8 @@ import (
 )
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound  = errors.New("not found")
+	ErrSlugTaken = errors.New("slug already in use")
 )
 
 // Page represents a wiki page.
@@ -24,6 +25,9 @@ type Page struct {
 	FirstCitedByUserID *int64
 	FirstCitedInPageID *int64
 	DeletedAt          *time.Time
+	RedirectTo         string // target slug of a #REDIRECT page
+	RoundID            *int64 // game round the entry was written in
+	Sealed             bool   // hidden from other players until its round is published
 	CreatedAt          time.Time
 	UpdatedAt          time.Time
 }
@@ -66,12 +70,12 @@ func Slugify(title string) string {
 func (db *DB) GetPageBySlug(slug string) (*Page, error) {
 	page := &Page{}
 	err := db.QueryRow(`
-		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, created_at, updated_at
+		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, redirect_to, round_id, sealed, created_at, updated_at
 		FROM pages WHERE slug = ?
 	`, slug).Scan(
 		&page.ID, &page.Slug, &page.Title, &page.IsPhantom,
 		&page.FirstCitedByUserID, &page.FirstCitedInPageID,
-		&page.DeletedAt, &page.CreatedAt, &page.UpdatedAt,
+		&page.DeletedAt, &page.RedirectTo, &page.RoundID, &page.Sealed, &page.CreatedAt, &page.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
@@ -86,12 +90,12 @@ func (db *DB) GetPageBySlug(slug string) (*Page, error) {
 func (db *DB) GetPageByID(id int64) (*Page, error) {
 	page := &Page{}
 	err := db.QueryRow(`
-		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, created_at, updated_at
+		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, redirect_to, round_id, sealed, created_at, updated_at
 		FROM pages WHERE id = ?
 	`, id).Scan(
 		&page.ID, &page.Slug, &page.Title, &page.IsPhantom,
 		&page.FirstCitedByUserID, &page.FirstCitedInPageID,
-		&page.DeletedAt, &page.CreatedAt, &page.UpdatedAt,
+		&page.DeletedAt, &page.RedirectTo, &page.RoundID, &page.Sealed, &page.CreatedAt, &page.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
@@ -102,8 +106,8 @@ func (db *DB) GetPageByID(id int64) (*Page, error) {
 	return page, nil
 }
 
-// CreatePage creates a new page (non-phantom with content).
-func (db *DB) CreatePage(slug, title, content string, authorID int64) (*Page, error) {
+// CreatePage creates a new page (non-phantom with content) and records its links.
+func (db *DB) CreatePage(slug, title, content string, authorID int64, links []Link, meta RevisionMeta) (*Page, error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
@@ -124,19 +128,34 @@ func (db *DB) CreatePage(slug, title, content string, authorID int64) (*Page, er
 	if err == sql.ErrNoRows {
 		// Create new page
 		result, err := tx.Exec(`
-			INSERT INTO pages (slug, title, is_phantom, created_at, updated_at)
-			VALUES (?, ?, 0, ?, ?)
-		`, slug, title, now, now)
+			INSERT INTO pages (slug, title, is_phantom, redirect_to, round_id, sealed, created_at, updated_at)
+			VALUES (?, ?, 0, ?, `+openRoundID+`, `+sealNewEntry+`, ?, ?)
+		`, slug, title, RedirectTarget(content), now, now)
 		if err != nil {
 			return nil, err
 		}
 		pageID, _ = result.LastInsertId()
 	} else if isPhantom {
+		// Only the claimant may write a claimed phantom
+		var claimant int64
+		err = tx.QueryRow(`
+			SELECT user_id FROM claims WHERE page_id = ? AND expires_at > ?
+		`, existingID, now).Scan(&claimant)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil && claimant != authorID {
+			return nil, ErrClaimed
+		}
+		if _, err := tx.Exec("DELETE FROM claims WHERE page_id = ?", existingID); err != nil {
+			return nil, err
+		}
+
 		// Convert phantom to real page
 		_, err = tx.Exec(`
-			UPDATE pages SET title = ?, is_phantom = 0, updated_at = ?
+			UPDATE pages SET title = ?, is_phantom = 0, redirect_to = ?, round_id = `+openRoundID+`, sealed = `+sealNewEntry+`, updated_at = ?
 			WHERE id = ?
-		`, title, now, existingID)
+		`, title, RedirectTarget(content), now, existingID)
 		if err != nil {
 			return nil, err
 		}
@@ -145,14 +164,28 @@ func (db *DB) CreatePage(slug, title, content string, authorID int64) (*Page, er
 		return nil, errors.New("page already exists")
 	}
 
+	// A new page takes over the slug from any redirect left by a move
+	if _, err := tx.Exec("DELETE FROM redirects WHERE from_slug = ?", slug); err != nil {
+		return nil, err
+	}
+
 	// Create first revision
-	_, err = tx.Exec(`
-		INSERT INTO revisions (page_id, content, author_id, created_at)
-		VALUES (?, ?, ?, ?)
-	`, pageID, content, authorID, now)
+	result, err := tx.Exec(`
+		INSERT INTO revisions (page_id, content, author_id, summary, is_minor, round_id, created_at)
+		VALUES (?, ?, ?, ?, ?, `+openRoundID+`, ?)
+	`, pageID, content, authorID, meta.Summary, meta.IsMinor, now)
+	if err != nil {
+		return nil, err
+	}
+	revisionID, _ := result.LastInsertId()
+
+	removed, err := replaceLinks(tx, pageID, revisionID, links)
 	if err != nil {
 		return nil, err
 	}
+	if err := reconcilePhantoms(tx, pageID, removed); err != nil {
+		return nil, err
+	}
 
 	// Update FTS index
 	_, err = tx.Exec(`
@@ -169,8 +202,8 @@ func (db *DB) CreatePage(slug, title, content string, authorID int64) (*Page, er
 	return db.GetPageByID(pageID)
 }
 
-// UpdatePage adds a new revision to an existing page.
-func (db *DB) UpdatePage(pageID int64, title, content string, authorID int64) error {
+// UpdatePage adds a new revision to an existing page and updates its links.
+func (db *DB) UpdatePage(pageID int64, title, content string, authorID int64, links []Link, meta RevisionMeta) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
@@ -181,20 +214,29 @@ func (db *DB) UpdatePage(pageID int64, title, content string, authorID int64) er
 
 	// Update page metadata
 	_, err = tx.Exec(`
-		UPDATE pages SET title = ?, updated_at = ? WHERE id = ?
-	`, title, now, pageID)
+		UPDATE pages SET title = ?, redirect_to = ?, updated_at = ? WHERE id = ?
+	`, title, RedirectTarget(content), now, pageID)
 	if err != nil {
 		return err
 	}
 
 	// Create new revision
-	_, err = tx.Exec(`
-		INSERT INTO revisions (page_id, content, author_id, created_at)
-		VALUES (?, ?, ?, ?)
-	`, pageID, content, authorID, now)
+	result, err := tx.Exec(`
+		INSERT INTO revisions (page_id, content, author_id, revert_of_id, summary, is_minor, round_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, `+openRoundID+`, ?)
+	`, pageID, content, authorID, meta.RevertOfID, meta.Summary, meta.IsMinor, now)
+	if err != nil {
+		return err
+	}
+	revisionID, _ := result.LastInsertId()
+
+	removed, err := replaceLinks(tx, pageID, revisionID, links)
 	if err != nil {
 		return err
 	}
+	if err := reconcilePhantoms(tx, pageID, removed); err != nil {
+		return err
+	}
 
 	// Update FTS index
 	_, err = tx.Exec(`DELETE FROM pages_fts WHERE rowid = ?`, pageID)
@@ -222,6 +264,13 @@ func (db *DB) CreatePhantom(slug, title string, citedByUserID, citedInPageID int
 		return nil, err
 	}
 
+	// A moved page still answers for its old slug
+	if target, err := db.ResolveRedirect(slug); err == nil {
+		return target, nil
+	} else if err != ErrNotFound {
+		return nil, err
+	}
+
 	now := time.Now()
 	result, err := db.Exec(`
 		INSERT INTO pages (slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, created_at, updated_at)
@@ -235,10 +284,23 @@ func (db *DB) CreatePhantom(slug, title string, citedByUserID, citedInPageID int
 	return db.GetPageByID(id)
 }
 
-// PageExists checks if a page exists (phantom or not).
+// SetPhantomCitation records who first cited a phantom and in which page.
+// Either may be nil if unknown.
+func (db *DB) SetPhantomCitation(pageID int64, citedByUserID, citedInPageID *int64) error {
+	_, err := db.Exec(`
+		UPDATE pages SET first_cited_by_user_id = ?, first_cited_in_page_id = ?
+		WHERE id = ? AND is_phantom = 1
+	`, citedByUserID, citedInPageID, pageID)
+	return err
+}
+
+// PageExists checks if a page exists (phantom or not) or a redirect answers
+// for the slug.
 func (db *DB) PageExists(slug string) (bool, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM pages WHERE slug = ?", slug).Scan(&count)
+	err := db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM pages WHERE slug = ?) + (SELECT COUNT(*) FROM redirects WHERE from_slug = ?)
+	`, slug, slug).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -258,9 +320,16 @@ func (db *DB) IsPhantom(slug string) (bool, error) {
 	return isPhantom, nil
 }
 
-// SoftDeletePage marks a page as deleted.
+// SoftDeletePage marks a page as deleted. Its links are dropped, so phantoms
+// only it cited are retired and others are credited to their next citation.
 func (db *DB) SoftDeletePage(pageID int64) error {
-	result, err := db.Exec(
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
 		"UPDATE pages SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL",
 		pageID,
 	)
@@ -275,23 +344,42 @@ func (db *DB) SoftDeletePage(pageID int64) error {
 		return ErrNotFound
 	}
 
+	removed, err := replaceLinks(tx, pageID, 0, nil)
+	if err != nil {
+		return err
+	}
+	if err := reconcilePhantoms(tx, pageID, removed); err != nil {
+		return err
+	}
+
 	// Remove from FTS index
-	db.Exec("DELETE FROM pages_fts WHERE rowid = ?", pageID)
+	if _, err := tx.Exec("DELETE FROM pages_fts WHERE rowid = ?", pageID); err != nil {
+		return err
+	}
 
-	return nil
+	return tx.Commit()
 }
 
-// RestorePage restores a soft-deleted page.
-func (db *DB) RestorePage(pageID int64) error {
+// RestorePage restores a soft-deleted page with the links of its current
+// revision. Phantoms for cited pages that no longer exist are left to the
+// caller, as on save.
+func (db *DB) RestorePage(pageID int64, links []Link) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// Get the page to restore
 	var title, content string
-	err := db.QueryRow(`
-		SELECT p.title, COALESCE(r.content, '')
+	var revisionID sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT p.title, r.id, COALESCE(r.content, '')
 		FROM pages p
 		LEFT JOIN revisions r ON r.page_id = p.id
 		WHERE p.id = ? AND p.deleted_at IS NOT NULL
-		ORDER BY r.created_at DESC LIMIT 1
-	`, pageID).Scan(&title, &content)
+		ORDER BY r.created_at DESC, r.id DESC LIMIT 1
+	`, pageID).Scan(&title, &revisionID, &content)
 	if err == sql.ErrNoRows {
 		return ErrNotFound
 	}
@@ -300,7 +388,7 @@ func (db *DB) RestorePage(pageID int64) error {
 	}
 
 	// Restore the page
-	_, err = db.Exec(
+	_, err = tx.Exec(
 		"UPDATE pages SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
 		pageID,
 	)
@@ -308,16 +396,28 @@ func (db *DB) RestorePage(pageID int64) error {
 		return err
 	}
 
+	if revisionID.Valid {
+		if _, err := replaceLinks(tx, pageID, revisionID.Int64, links); err != nil {
+			return err
+		}
+	}
+
 	// Re-add to FTS index
-	db.Exec("INSERT INTO pages_fts (rowid, title, content) VALUES (?, ?, ?)", pageID, title, content)
+	if _, err := tx.Exec("DELETE FROM pages_fts WHERE rowid = ?", pageID); err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO pages_fts (rowid, title, content) VALUES (?, ?, ?)", pageID, title, content)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return tx.Commit()
 }
 
 // ListDeletedPages returns all soft-deleted pages.
 func (db *DB) ListDeletedPages() ([]*Page, error) {
 	rows, err := db.Query(`
-		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, created_at, updated_at
+		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, redirect_to, round_id, sealed, created_at, updated_at
 		FROM pages WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC
 	`)
 	if err != nil {
@@ -328,11 +428,12 @@ func (db *DB) ListDeletedPages() ([]*Page, error) {
 	return scanPages(rows)
 }
 
-// ListPages returns all non-phantom, non-deleted pages ordered alphabetically.
+// ListPages returns all non-phantom, non-deleted, unsealed pages ordered
+// alphabetically.
 func (db *DB) ListPages() ([]*Page, error) {
 	rows, err := db.Query(`
-		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, created_at, updated_at
-		FROM pages WHERE is_phantom = 0 AND deleted_at IS NULL ORDER BY title ASC
+		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, redirect_to, round_id, sealed, created_at, updated_at
+		FROM pages WHERE is_phantom = 0 AND deleted_at IS NULL AND sealed = 0 ORDER BY title ASC
 	`)
 	if err != nil {
 		return nil, err
@@ -347,12 +448,16 @@ type PhantomWithSource struct {
 	*Page
 	SourceSlug  string
 	SourceTitle string
+
+	// Username and expiry of an unexpired claim, if any
+	ClaimedBy      string
+	ClaimExpiresAt *time.Time
 }
 
 // ListPhantoms returns all non-deleted phantom pages ordered alphabetically.
 func (db *DB) ListPhantoms() ([]*Page, error) {
 	rows, err := db.Query(`
-		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, created_at, updated_at
+		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, redirect_to, round_id, sealed, created_at, updated_at
 		FROM pages WHERE is_phantom = 1 AND deleted_at IS NULL ORDER BY title ASC
 	`)
 	if err != nil {
@@ -366,18 +471,25 @@ func (db *DB) ListPhantoms() ([]*Page, error) {
 // ListPhantomsWithSource returns all non-deleted phantom pages with their source page info.
 func (db *DB) ListPhantomsWithSource() ([]*PhantomWithSource, error) {
 	rows, err := db.Query(`
-		SELECT p.id, p.slug, p.title, p.is_phantom, p.first_cited_by_user_id, p.first_cited_in_page_id, p.deleted_at, p.created_at, p.updated_at,
-		       COALESCE(src.slug, '') as source_slug, COALESCE(src.title, '') as source_title
+		SELECT p.id, p.slug, p.title, p.is_phantom, p.first_cited_by_user_id, p.first_cited_in_page_id, p.deleted_at, p.redirect_to, p.round_id, p.sealed, p.created_at, p.updated_at,
+		       COALESCE(src.slug, '') as source_slug, COALESCE(src.title, '') as source_title,
+		       COALESCE(cu.username, '') as claimed_by, c.expires_at
 		FROM pages p
-		LEFT JOIN pages src ON p.first_cited_in_page_id = src.id
+		LEFT JOIN pages src ON p.first_cited_in_page_id = src.id AND src.sealed = 0
+		LEFT JOIN claims c ON c.page_id = p.id AND c.expires_at > ?
+		LEFT JOIN users cu ON c.user_id = cu.id
 		WHERE p.is_phantom = 1 AND p.deleted_at IS NULL
 		ORDER BY p.title ASC
-	`)
+	`, time.Now())
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanPhantomsWithSource(rows)
+}
+
+func scanPhantomsWithSource(rows *sql.Rows) ([]*PhantomWithSource, error) {
 	var phantoms []*PhantomWithSource
 	for rows.Next() {
 		page := &Page{}
@@ -385,8 +497,9 @@ func (db *DB) ListPhantomsWithSource() ([]*PhantomWithSource, error) {
 		err := rows.Scan(
 			&page.ID, &page.Slug, &page.Title, &page.IsPhantom,
 			&page.FirstCitedByUserID, &page.FirstCitedInPageID,
-			&page.DeletedAt, &page.CreatedAt, &page.UpdatedAt,
+			&page.DeletedAt, &page.RedirectTo, &page.RoundID, &page.Sealed, &page.CreatedAt, &page.UpdatedAt,
 			&phantom.SourceSlug, &phantom.SourceTitle,
+			&phantom.ClaimedBy, &phantom.ClaimExpiresAt,
 		)
 		if err != nil {
 			return nil, err
@@ -396,11 +509,11 @@ func (db *DB) ListPhantomsWithSource() ([]*PhantomWithSource, error) {
 	return phantoms, rows.Err()
 }
 
-// ListRecentPages returns recently modified non-deleted pages.
+// ListRecentPages returns recently modified non-deleted, unsealed pages.
 func (db *DB) ListRecentPages(limit int) ([]*Page, error) {
 	rows, err := db.Query(`
-		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, created_at, updated_at
-		FROM pages WHERE is_phantom = 0 AND deleted_at IS NULL ORDER BY updated_at DESC LIMIT ?
+		SELECT id, slug, title, is_phantom, first_cited_by_user_id, first_cited_in_page_id, deleted_at, redirect_to, round_id, sealed, created_at, updated_at
+		FROM pages WHERE is_phantom = 0 AND deleted_at IS NULL AND sealed = 0 ORDER BY updated_at DESC LIMIT ?
 	`, limit)
 	if err != nil {
 		return nil, err
@@ -412,7 +525,7 @@ func (db *DB) ListRecentPages(limit int) ([]*Page, error) {
 
 // PageStats returns counts of non-deleted pages and phantoms.
 func (db *DB) PageStats() (pages, phantoms int, err error) {
-	err = db.QueryRow("SELECT COUNT(*) FROM pages WHERE is_phantom = 0 AND deleted_at IS NULL").Scan(&pages)
+	err = db.QueryRow("SELECT COUNT(*) FROM pages WHERE is_phantom = 0 AND deleted_at IS NULL AND sealed = 0").Scan(&pages)
 	if err != nil {
 		return
 	}
@@ -427,7 +540,7 @@ func scanPages(rows *sql.Rows) ([]*Page, error) {
 		err := rows.Scan(
 			&page.ID, &page.Slug, &page.Title, &page.IsPhantom,
 			&page.FirstCitedByUserID, &page.FirstCitedInPageID,
-			&page.DeletedAt, &page.CreatedAt, &page.UpdatedAt,
+			&page.DeletedAt, &page.RedirectTo, &page.RoundID, &page.Sealed, &page.CreatedAt, &page.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,252 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Assignment statuses, as reported by Assignment.Status.
+const (
+	AssignmentUpcoming = "upcoming"
+	AssignmentDue      = "due"
+	AssignmentWritten  = "written"
+	AssignmentOverdue  = "overdue"
+)
+
+// ErrAlreadyAssigned is returned when an entry is assigned to two players in
+// the same round.
+var ErrAlreadyAssigned = errors.New("entry already assigned in this round")
+
+// Player is the in-world scholar persona a user writes as.
+type Player struct {
+	ID          int64
+	UserID      int64
+	DisplayName string
+	BioSlug     string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	// Joined fields
+	Username string
+}
+
+// Assignment is the entry a player is to write in a round.
+type Assignment struct {
+	PlayerID  int64
+	RoundID   int64
+	Slug      string
+	Title     string
+	CreatedAt time.Time
+
+	// Joined fields
+	PlayerName    string
+	PlayerUserID  int64
+	RoundNumber   int
+	RoundLabel    string
+	RoundStatus   string
+	RoundDeadline *time.Time
+	PageID        *int64 // nil if nothing exists at the slug yet
+	IsPhantom     bool
+	PageAuthorID  *int64 // author of the page's first revision
+}
+
+// IsWritten reports whether the assigned player wrote the page for the
+// assignment. A page someone else wrote at the slug doesn't count.
+func (a *Assignment) IsWritten() bool {
+	return a.PageID != nil && !a.IsPhantom && a.PageAuthorID != nil && *a.PageAuthorID == a.PlayerUserID
+}
+
+// Status reports the progress of the assignment.
+func (a *Assignment) Status() string {
+	switch {
+	case a.IsWritten():
+		return AssignmentWritten
+	case a.RoundStatus == RoundClosed, a.RoundDeadline != nil && time.Now().After(*a.RoundDeadline):
+		return AssignmentOverdue
+	case a.RoundStatus == RoundPending:
+		return AssignmentUpcoming
+	default:
+		return AssignmentDue
+	}
+}
+
+const playerColumns = `pl.id, pl.user_id, pl.display_name, pl.bio_slug, pl.created_at, pl.updated_at, u.username`
+
+func scanPlayer(row rowScanner) (*Player, error) {
+	player := &Player{}
+	err := row.Scan(
+		&player.ID, &player.UserID, &player.DisplayName, &player.BioSlug,
+		&player.CreatedAt, &player.UpdatedAt, &player.Username,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return player, nil
+}
+
+// GetPlayerByUserID retrieves a user's persona.
+func (db *DB) GetPlayerByUserID(userID int64) (*Player, error) {
+	return scanPlayer(db.QueryRow(`
+		SELECT `+playerColumns+` FROM players pl JOIN users u ON pl.user_id = u.id WHERE pl.user_id = ?
+	`, userID))
+}
+
+// ListPlayers returns all personas ordered by display name.
+func (db *DB) ListPlayers() ([]*Player, error) {
+	rows, err := db.Query(`
+		SELECT ` + playerColumns + ` FROM players pl JOIN users u ON pl.user_id = u.id
+		ORDER BY pl.display_name COLLATE NOCASE
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var players []*Player
+	for rows.Next() {
+		player, err := scanPlayer(rows)
+		if err != nil {
+			return nil, err
+		}
+		players = append(players, player)
+	}
+	return players, rows.Err()
+}
+
+// SavePlayer creates or updates a user's persona.
+func (db *DB) SavePlayer(userID int64, displayName, bioSlug string) (*Player, error) {
+	now := time.Now()
+	_, err := db.Exec(`
+		INSERT INTO players (user_id, display_name, bio_slug, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			display_name = excluded.display_name, bio_slug = excluded.bio_slug, updated_at = excluded.updated_at
+	`, userID, displayName, bioSlug, now, now)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPlayerByUserID(userID)
+}
+
+// EnsurePlayer returns a user's persona, creating one named after the user
+// if they don't have one yet.
+func (db *DB) EnsurePlayer(userID int64) (*Player, error) {
+	player, err := db.GetPlayerByUserID(userID)
+	if err != ErrNotFound {
+		return player, err
+	}
+
+	user, err := db.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return db.SavePlayer(userID, user.Username, "")
+}
+
+// AssignEntry sets the entry a player is to write in a round. An empty slug
+// removes the assignment.
+func (db *DB) AssignEntry(playerID, roundID int64, slug, title string) error {
+	if slug == "" {
+		_, err := db.Exec("DELETE FROM assignments WHERE player_id = ? AND round_id = ?", playerID, roundID)
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var holder int64
+	err = tx.QueryRow(`
+		SELECT player_id FROM assignments WHERE round_id = ? AND slug = ?
+	`, roundID, slug).Scan(&holder)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && holder != playerID {
+		return ErrAlreadyAssigned
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO assignments (player_id, round_id, slug, title, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (player_id, round_id) DO UPDATE SET slug = excluded.slug, title = excluded.title
+	`, playerID, roundID, slug, title, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const assignmentQuery = `
+	SELECT a.player_id, a.round_id, a.slug, a.title, a.created_at,
+		pl.display_name, pl.user_id, r.number, r.label, r.status, r.deadline, p.id, COALESCE(p.is_phantom, 0),
+		(SELECT author_id FROM revisions WHERE page_id = p.id ORDER BY id ASC LIMIT 1)
+	FROM assignments a
+	JOIN players pl ON a.player_id = pl.id
+	JOIN rounds r ON a.round_id = r.id
+	LEFT JOIN pages p ON p.slug = a.slug AND p.deleted_at IS NULL
+`
+
+func scanAssignments(rows *sql.Rows) ([]*Assignment, error) {
+	var assignments []*Assignment
+	for rows.Next() {
+		a := &Assignment{}
+		err := rows.Scan(
+			&a.PlayerID, &a.RoundID, &a.Slug, &a.Title, &a.CreatedAt,
+			&a.PlayerName, &a.PlayerUserID, &a.RoundNumber, &a.RoundLabel, &a.RoundStatus, &a.RoundDeadline,
+			&a.PageID, &a.IsPhantom, &a.PageAuthorID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+// GetAssignment returns a player's assignment in a round.
+func (db *DB) GetAssignment(playerID, roundID int64) (*Assignment, error) {
+	rows, err := db.Query(assignmentQuery+`WHERE a.player_id = ? AND a.round_id = ?`, playerID, roundID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments, err := scanAssignments(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(assignments) == 0 {
+		return nil, ErrNotFound
+	}
+	return assignments[0], nil
+}
+
+// ListRoundAssignments returns the assignments of a round by player name.
+func (db *DB) ListRoundAssignments(roundID int64) ([]*Assignment, error) {
+	rows, err := db.Query(assignmentQuery+`WHERE a.round_id = ? ORDER BY pl.display_name COLLATE NOCASE`, roundID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAssignments(rows)
+}
+
+// ListPlayerAssignments returns a player's assignments in round order.
+func (db *DB) ListPlayerAssignments(playerID int64) ([]*Assignment, error) {
+	rows, err := db.Query(assignmentQuery+`WHERE a.player_id = ? ORDER BY r.number`, playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAssignments(rows)
+}
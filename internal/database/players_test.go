@@ -0,0 +1,77 @@
+package database
+
+import "testing"
+
+func TestAssignments(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := db.CreateUser("bob", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quill, err := db.SavePlayer(alice.ID, "Prof. Quill", "prof-quill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Players without a persona get one named after the login
+	bobPlayer, err := db.EnsurePlayer(bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bobPlayer.DisplayName != "bob" {
+		t.Errorf("EnsurePlayer display name = %q, want bob", bobPlayer.DisplayName)
+	}
+
+	round, err := db.CreateRound("A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AssignEntry(quill.ID, round.ID, "aardvarks", "Aardvarks"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AssignEntry(bobPlayer.ID, round.ID, "aardvarks", "Aardvarks"); err != ErrAlreadyAssigned {
+		t.Errorf("assigning a taken entry: error = %v, want ErrAlreadyAssigned", err)
+	}
+
+	a, err := db.GetAssignment(quill.ID, round.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status() != AssignmentUpcoming {
+		t.Errorf("status before round opens = %q, want %q", a.Status(), AssignmentUpcoming)
+	}
+
+	if _, err := db.OpenRound(round.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePage("aardvarks", "Aardvarks", "Burrowing.", alice.ID, nil, RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ = db.GetAssignment(quill.ID, round.ID); a.Status() != AssignmentWritten {
+		t.Errorf("status after writing = %q, want %q", a.Status(), AssignmentWritten)
+	}
+
+	// An entry someone else wrote isn't the player's
+	if err := db.AssignEntry(bobPlayer.ID, round.ID, "aardvarks-again", "Aardvarks Again"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePage("aardvarks-again", "Aardvarks Again", "Still burrowing.", alice.ID, nil, RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := db.GetAssignment(bobPlayer.ID, round.ID); a.Status() != AssignmentDue {
+		t.Errorf("status after someone else wrote it = %q, want %q", a.Status(), AssignmentDue)
+	}
+
+	// Clearing the slug removes the assignment
+	if err := db.AssignEntry(quill.ID, round.ID, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetAssignment(quill.ID, round.ID); err != ErrNotFound {
+		t.Errorf("GetAssignment after clearing: error = %v, want ErrNotFound", err)
+	}
+}
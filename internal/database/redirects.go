@@ -0,0 +1,255 @@
+package database
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Redirect maps a former slug or an alias to the page holding it.
+type Redirect struct {
+	FromSlug  string
+	PageID    int64
+	IsAlias   bool
+	Title     string // alias as written; empty for former slugs
+	CreatedAt time.Time
+}
+
+var redirectPattern = regexp.MustCompile(`(?i)^\s*#REDIRECT\s*\[\[([^\]|\n]+)(?:\|[^\]\n]*)?\]\]`)
+
+// RedirectTarget returns the slug named by a "#REDIRECT [[Target]]" line at
+// the start of content, or "" if the content is not a redirect.
+func RedirectTarget(content string) string {
+	m := redirectPattern.FindStringSubmatch(content)
+	if m == nil {
+		return ""
+	}
+	return Slugify(strings.TrimSpace(m[1]))
+}
+
+// ResolveRedirect returns the page a redirected slug points to.
+func (db *DB) ResolveRedirect(slug string) (*Page, error) {
+	var pageID int64
+	err := db.QueryRow("SELECT page_id FROM redirects WHERE from_slug = ?", slug).Scan(&pageID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPageByID(pageID)
+}
+
+// ResolveCitation returns the page a link to slug leads to: the page there,
+// the target of an alias or a move, or the page a #REDIRECT page points to,
+// followed one step as viewing it does. Deleted pages count as missing.
+func (db *DB) ResolveCitation(slug string) (*Page, error) {
+	page, err := db.livePage(slug)
+	if err != nil {
+		return nil, err
+	}
+	if page.RedirectTo != "" && page.RedirectTo != page.Slug {
+		return db.livePage(page.RedirectTo)
+	}
+	return page, nil
+}
+
+// livePage returns the page at slug or the one it redirects to, unless it's
+// deleted.
+func (db *DB) livePage(slug string) (*Page, error) {
+	page, err := db.GetPageBySlug(slug)
+	if err == ErrNotFound {
+		page, err = db.ResolveRedirect(slug)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if page.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return page, nil
+}
+
+// ListRedirects returns the aliases and former slugs redirecting to a page,
+// aliases first.
+func (db *DB) ListRedirects(pageID int64) ([]*Redirect, error) {
+	rows, err := db.Query(`
+		SELECT from_slug, page_id, is_alias, title, created_at FROM redirects
+		WHERE page_id = ? ORDER BY is_alias DESC, created_at DESC
+	`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var redirects []*Redirect
+	for rows.Next() {
+		r := &Redirect{}
+		if err := rows.Scan(&r.FromSlug, &r.PageID, &r.IsAlias, &r.Title, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		redirects = append(redirects, r)
+	}
+	return redirects, rows.Err()
+}
+
+// MovePage changes a page's slug and title, leaving a redirect at the old
+// slug. A phantom holding the new slug is absorbed, so links that cited it
+// resolve to the moved page. Returns ErrSlugTaken if a real page (deleted
+// or not) or another page's alias already holds the new slug.
+func (db *DB) MovePage(pageID int64, newSlug, newTitle string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldSlug string
+	err = tx.QueryRow("SELECT slug FROM pages WHERE id = ?", pageID).Scan(&oldSlug)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var existingID int64
+	var isPhantom bool
+	err = tx.QueryRow("SELECT id, is_phantom FROM pages WHERE slug = ?", newSlug).Scan(&existingID, &isPhantom)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	case existingID == pageID:
+	case !isPhantom:
+		return ErrSlugTaken
+	default:
+		if _, err := tx.Exec("DELETE FROM pages WHERE id = ?", existingID); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE pages SET slug = ?, title = ?, updated_at = ? WHERE id = ?
+	`, newSlug, newTitle, now, pageID)
+	if err != nil {
+		return err
+	}
+
+	// Aliases of other pages are kept; former slugs can be taken over
+	var aliasOwner int64
+	err = tx.QueryRow("SELECT page_id FROM redirects WHERE from_slug = ? AND is_alias = 1", newSlug).Scan(&aliasOwner)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && aliasOwner != pageID {
+		return ErrSlugTaken
+	}
+
+	// Moving back to a former slug replaces its redirect
+	if _, err := tx.Exec("DELETE FROM redirects WHERE from_slug = ?", newSlug); err != nil {
+		return err
+	}
+	if oldSlug != newSlug {
+		_, err = tx.Exec(`
+			INSERT INTO redirects (from_slug, page_id, created_at) VALUES (?, ?, ?)
+		`, oldSlug, pageID, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Update FTS index
+	if _, err := tx.Exec("UPDATE pages_fts SET title = ? WHERE rowid = ?", newTitle, pageID); err != nil {
+		return err
+	}
+
+	// Assignments follow the entry to its new slug
+	_, err = tx.Exec(`
+		UPDATE assignments SET slug = ?, title = ? WHERE slug = ?
+	`, newSlug, newTitle, oldSlug)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetAliases replaces a page's aliases. Phantoms holding an alias slug are
+// absorbed. Titles whose slug belongs to another page or redirect are
+// skipped and returned.
+func (db *DB) SetAliases(pageID int64, titles []string) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var pageSlug string
+	err = tx.QueryRow("SELECT slug FROM pages WHERE id = ?", pageID).Scan(&pageSlug)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM redirects WHERE page_id = ? AND is_alias = 1", pageID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	seen := map[string]bool{pageSlug: true}
+	var rejected []string
+
+	for _, title := range titles {
+		title = strings.TrimSpace(title)
+		slug := Slugify(title)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		var existingID int64
+		var isPhantom bool
+		err := tx.QueryRow("SELECT id, is_phantom FROM pages WHERE slug = ?", slug).Scan(&existingID, &isPhantom)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return nil, err
+		case !isPhantom:
+			rejected = append(rejected, title)
+			continue
+		default:
+			if _, err := tx.Exec("DELETE FROM pages WHERE id = ?", existingID); err != nil {
+				return nil, err
+			}
+		}
+
+		// A former slug of this page becomes an alias; other pages keep theirs
+		var ownerID int64
+		err = tx.QueryRow("SELECT page_id FROM redirects WHERE from_slug = ?", slug).Scan(&ownerID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil && ownerID != pageID {
+			rejected = append(rejected, title)
+			continue
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO redirects (from_slug, page_id, is_alias, title, created_at) VALUES (?, ?, 1, ?, ?)
+			ON CONFLICT(from_slug) DO UPDATE SET is_alias = 1, title = excluded.title
+		`, slug, pageID, title, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rejected, nil
+}
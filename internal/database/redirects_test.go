@@ -0,0 +1,188 @@
+package database
+
+import "testing"
+
+func TestMovePage(t *testing.T) {
+	db := openTestDB(t)
+
+	user, err := db.CreateUser("testuser", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	battle, err := db.CreatePage("teh-battle", "Teh Battle", "A battle.", user.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.CreatePage("war", "War", "See [[Teh Battle]] and [[The Battle]].", user.ID, []Link{
+		{TargetSlug: "teh-battle", DisplayText: "Teh Battle"},
+		{TargetSlug: "the-battle", DisplayText: "The Battle"},
+	}, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePhantom("the-battle", "The Battle", user.ID, battle.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePage("dragons", "Dragons", "Dragons.", user.ID, nil, RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.MovePage(battle.ID, "dragons", "Dragons"); err != ErrSlugTaken {
+		t.Errorf("move onto a real page: err = %v, want ErrSlugTaken", err)
+	}
+
+	// The phantom at the new slug is absorbed
+	if err := db.MovePage(battle.ID, "the-battle", "The Battle"); err != nil {
+		t.Fatal(err)
+	}
+	page, err := db.GetPageBySlug("the-battle")
+	if err != nil || page.ID != battle.ID || page.IsPhantom {
+		t.Fatalf("the-battle = %+v, %v; want the moved page", page, err)
+	}
+
+	target, err := db.ResolveRedirect("teh-battle")
+	if err != nil || target.ID != battle.ID {
+		t.Errorf("redirect from teh-battle = %+v, %v; want the moved page", target, err)
+	}
+	if exists, _ := db.PageExists("teh-battle"); !exists {
+		t.Error("PageExists(teh-battle) = false, want true for a redirect")
+	}
+
+	// Links to either slug count once
+	if got := backlinkSlugs(t, db, "the-battle"); len(got) != 1 || got[0] != "war" {
+		t.Errorf("backlinks of the-battle = %v, want [war]", got)
+	}
+
+	// Moving back replaces the redirect
+	if err := db.MovePage(battle.ID, "teh-battle", "Teh Battle"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ResolveRedirect("teh-battle"); err != ErrNotFound {
+		t.Errorf("redirect from current slug: err = %v, want ErrNotFound", err)
+	}
+	if target, err := db.ResolveRedirect("the-battle"); err != nil || target.ID != battle.ID {
+		t.Errorf("redirect from the-battle = %+v, %v; want the moved page", target, err)
+	}
+}
+
+func TestRedirectTarget(t *testing.T) {
+	tests := map[string]string{
+		"#REDIRECT [[The Red King]]":            "the-red-king",
+		"  #redirect[[King Aldous|the king]]\n": "king-aldous",
+		"Text first\n#REDIRECT [[Elsewhere]]":   "",
+		"#REDIRECT The Red King":                "",
+	}
+	for content, want := range tests {
+		if got := RedirectTarget(content); got != want {
+			t.Errorf("RedirectTarget(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+func TestSetAliases(t *testing.T) {
+	db := openTestDB(t)
+
+	user, err := db.CreateUser("testuser", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	king, err := db.CreatePage("king-aldous", "King Aldous", "A king.", user.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePage("dragons", "Dragons", "Dragons.", user.ID, nil, RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePhantom("the-red-king", "The Red King", user.ID, king.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected, err := db.SetAliases(king.ID, []string{"The Red King", "Dragons", "King Aldous", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 1 || rejected[0] != "Dragons" {
+		t.Errorf("rejected = %v, want [Dragons]", rejected)
+	}
+
+	// The phantom is absorbed by the alias
+	target, err := db.ResolveRedirect("the-red-king")
+	if err != nil || target.ID != king.ID {
+		t.Errorf("the-red-king resolves to %+v, %v; want King Aldous", target, err)
+	}
+	if phantom, err := db.CreatePhantom("the-red-king", "The Red King", user.ID, king.ID); err != nil || phantom.ID != king.ID {
+		t.Errorf("CreatePhantom on an alias = %+v, %v; want King Aldous", phantom, err)
+	}
+
+	redirects, err := db.ListRedirects(king.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(redirects) != 1 || !redirects[0].IsAlias || redirects[0].Title != "The Red King" {
+		t.Errorf("redirects = %+v, want the alias", redirects)
+	}
+
+	if _, err := db.SetAliases(king.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ResolveRedirect("the-red-king"); err != ErrNotFound {
+		t.Errorf("cleared alias still resolves: err = %v", err)
+	}
+}
+
+func TestResolveCitation(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := db.CreateUser("bob", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	elves, err := db.CreatePage("elves", "Elves", "Pointy ears.", alice.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetAliases(elves.ID, []string{"Wood Elves"}); err != nil {
+		t.Fatal(err)
+	}
+	links := []Link{{TargetSlug: "elves", DisplayText: "Elves"}}
+	if _, err := db.CreatePage("fair-folk", "Fair Folk", "#REDIRECT [[Elves]]", bob.ID, links, RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	gone, err := db.CreatePage("gone", "Gone", "Deleted later.", bob.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SoftDeletePage(gone.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePage("to-gone", "To Gone", "#REDIRECT [[Gone]]", bob.ID, nil, RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		slug string
+		want int64 // 0 for missing
+	}{
+		{"elves", elves.ID},
+		{"wood-elves", elves.ID},
+		{"fair-folk", elves.ID}, // judged as the page it redirects to
+		{"gone", 0},             // deleted pages count as missing
+		{"to-gone", 0},
+		{"nowhere", 0},
+	} {
+		page, err := db.ResolveCitation(tt.slug)
+		switch {
+		case tt.want == 0 && err != ErrNotFound:
+			t.Errorf("ResolveCitation(%q) = %v, %v; want ErrNotFound", tt.slug, page, err)
+		case tt.want != 0 && (err != nil || page.ID != tt.want):
+			t.Errorf("ResolveCitation(%q) = %v, %v; want page %d", tt.slug, page, err, tt.want)
+		}
+	}
+}
@@ -7,27 +7,42 @@ import (
 
 // Revision represents a page revision.
 type Revision struct {
-	ID        int64
-	PageID    int64
-	Content   string
-	AuthorID  int64
-	CreatedAt time.Time
+	ID         int64
+	PageID     int64
+	Content    string
+	AuthorID   int64
+	RevertOfID *int64
+	Summary    string
+	IsMinor    bool
+	RoundID    *int64
+	CreatedAt  time.Time
 
 	// Joined fields (not always populated)
 	AuthorUsername string
 }
 
-// GetCurrentRevision returns the most recent revision for a page.
-func (db *DB) GetCurrentRevision(pageID int64) (*Revision, error) {
+// RevisionMeta holds optional details recorded with a new revision.
+type RevisionMeta struct {
+	// RevertOfID is the earlier revision whose content this one restores.
+	RevertOfID *int64
+	// Summary is the author's short description of the change.
+	Summary string
+	// IsMinor marks typo fixes and other trivial changes.
+	IsMinor bool
+}
+
+const revisionColumns = `r.id, r.page_id, r.content, r.author_id, r.revert_of_id, r.summary, r.is_minor, r.round_id, r.created_at, u.username`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRevision(row rowScanner) (*Revision, error) {
 	rev := &Revision{}
-	err := db.QueryRow(`
-		SELECT r.id, r.page_id, r.content, r.author_id, r.created_at, u.username
-		FROM revisions r
-		JOIN users u ON r.author_id = u.id
-		WHERE r.page_id = ?
-		ORDER BY r.created_at DESC
-		LIMIT 1
-	`, pageID).Scan(&rev.ID, &rev.PageID, &rev.Content, &rev.AuthorID, &rev.CreatedAt, &rev.AuthorUsername)
+	err := row.Scan(
+		&rev.ID, &rev.PageID, &rev.Content, &rev.AuthorID, &rev.RevertOfID,
+		&rev.Summary, &rev.IsMinor, &rev.RoundID, &rev.CreatedAt, &rev.AuthorUsername,
+	)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
@@ -37,32 +52,50 @@ func (db *DB) GetCurrentRevision(pageID int64) (*Revision, error) {
 	return rev, nil
 }
 
+// GetCurrentRevision returns the most recent revision for a page.
+func (db *DB) GetCurrentRevision(pageID int64) (*Revision, error) {
+	return scanRevision(db.QueryRow(`
+		SELECT `+revisionColumns+`
+		FROM revisions r
+		JOIN users u ON r.author_id = u.id
+		WHERE r.page_id = ?
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT 1
+	`, pageID))
+}
+
 // GetRevisionByID returns a specific revision.
 func (db *DB) GetRevisionByID(revisionID int64) (*Revision, error) {
-	rev := &Revision{}
-	err := db.QueryRow(`
-		SELECT r.id, r.page_id, r.content, r.author_id, r.created_at, u.username
+	return scanRevision(db.QueryRow(`
+		SELECT `+revisionColumns+`
 		FROM revisions r
 		JOIN users u ON r.author_id = u.id
 		WHERE r.id = ?
-	`, revisionID).Scan(&rev.ID, &rev.PageID, &rev.Content, &rev.AuthorID, &rev.CreatedAt, &rev.AuthorUsername)
-	if err == sql.ErrNoRows {
-		return nil, ErrNotFound
-	}
-	if err != nil {
-		return nil, err
-	}
-	return rev, nil
+	`, revisionID))
+}
+
+// GetPreviousRevision returns the revision of the same page preceding the given one.
+func (db *DB) GetPreviousRevision(rev *Revision) (*Revision, error) {
+	return scanRevision(db.QueryRow(`
+		SELECT `+revisionColumns+`
+		FROM revisions r
+		JOIN users u ON r.author_id = u.id
+		JOIN revisions cur ON cur.id = ?
+		WHERE r.page_id = cur.page_id
+			AND (r.created_at < cur.created_at OR (r.created_at = cur.created_at AND r.id < cur.id))
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT 1
+	`, rev.ID))
 }
 
 // ListRevisions returns all revisions for a page, newest first.
 func (db *DB) ListRevisions(pageID int64) ([]*Revision, error) {
 	rows, err := db.Query(`
-		SELECT r.id, r.page_id, r.content, r.author_id, r.created_at, u.username
+		SELECT `+revisionColumns+`
 		FROM revisions r
 		JOIN users u ON r.author_id = u.id
 		WHERE r.page_id = ?
-		ORDER BY r.created_at DESC
+		ORDER BY r.created_at DESC, r.id DESC
 	`, pageID)
 	if err != nil {
 		return nil, err
@@ -71,8 +104,7 @@ func (db *DB) ListRevisions(pageID int64) ([]*Revision, error) {
 
 	var revisions []*Revision
 	for rows.Next() {
-		rev := &Revision{}
-		err := rows.Scan(&rev.ID, &rev.PageID, &rev.Content, &rev.AuthorID, &rev.CreatedAt, &rev.AuthorUsername)
+		rev, err := scanRevision(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -87,3 +119,95 @@ func (db *DB) RevisionCount(pageID int64) (int, error) {
 	err := db.QueryRow("SELECT COUNT(*) FROM revisions WHERE page_id = ?", pageID).Scan(&count)
 	return count, err
 }
+
+// DiscardHistory deletes every revision of a page but the current one.
+func (db *DB) DiscardHistory(pageID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current int64
+	err = tx.QueryRow(`
+		SELECT id FROM revisions WHERE page_id = ? ORDER BY created_at DESC, id DESC LIMIT 1
+	`, pageID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE revisions SET revert_of_id = NULL WHERE page_id = ? AND revert_of_id IS NOT NULL
+	`, pageID); err != nil {
+		return err
+	}
+	// Links point at the revision that added them, and would go with it
+	if _, err := tx.Exec("UPDATE links SET revision_id = ? WHERE source_page_id = ?", current, pageID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM revisions WHERE page_id = ? AND id != ?", pageID, current); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PageAuthor returns the author of a page's first revision.
+func (db *DB) PageAuthor(pageID int64) (int64, error) {
+	var authorID int64
+	err := db.QueryRow(`
+		SELECT author_id FROM revisions WHERE page_id = ? ORDER BY id ASC LIMIT 1
+	`, pageID).Scan(&authorID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return authorID, err
+}
+
+// RecentEdit is a revision together with the page it belongs to.
+type RecentEdit struct {
+	*Revision
+	PageSlug  string
+	PageTitle string
+}
+
+// ListRecentEdits returns the latest revisions of non-deleted pages, newest
+// first. Minor edits are skipped unless includeMinor is set.
+func (db *DB) ListRecentEdits(limit int, includeMinor bool) ([]*RecentEdit, error) {
+	rows, err := db.Query(`
+		SELECT `+revisionColumns+`, p.slug, p.title
+		FROM revisions r
+		JOIN users u ON r.author_id = u.id
+		JOIN pages p ON r.page_id = p.id
+		WHERE p.is_phantom = 0 AND p.deleted_at IS NULL AND p.sealed = 0 AND (? OR r.is_minor = 0)
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT ?
+	`, includeMinor, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRecentEdits(rows)
+}
+
+func scanRecentEdits(rows *sql.Rows) ([]*RecentEdit, error) {
+	var edits []*RecentEdit
+	for rows.Next() {
+		edit := &RecentEdit{Revision: &Revision{}}
+		rev := edit.Revision
+		err := rows.Scan(
+			&rev.ID, &rev.PageID, &rev.Content, &rev.AuthorID, &rev.RevertOfID,
+			&rev.Summary, &rev.IsMinor, &rev.RoundID, &rev.CreatedAt, &rev.AuthorUsername,
+			&edit.PageSlug, &edit.PageTitle,
+		)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, rows.Err()
+}
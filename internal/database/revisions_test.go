@@ -0,0 +1,92 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListRecentEdits(t *testing.T) {
+	db := openTestDB(t)
+
+	user, err := db.CreateUser("testuser", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := db.CreatePage("dragons", "Dragons", "Dragons breathe fire.", user.ID, nil, RevisionMeta{Summary: "First draft"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.UpdatePage(page.ID, "Dragons", "Dragons breathe fire!", user.ID, nil, RevisionMeta{Summary: "Punctuation", IsMinor: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edits, err := db.ListRecentEdits(10, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 2 {
+		t.Fatalf("got %d edits, want 2", len(edits))
+	}
+	if !edits[0].IsMinor || edits[0].Summary != "Punctuation" || edits[0].PageSlug != "dragons" {
+		t.Errorf("newest edit = %+v, want the minor punctuation fix", edits[0].Revision)
+	}
+
+	edits, err = db.ListRecentEdits(10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 1 || edits[0].Summary != "First draft" {
+		t.Errorf("without minor edits got %d edits, want only the first draft", len(edits))
+	}
+}
+
+func TestGetPreviousRevision(t *testing.T) {
+	db := openTestDB(t)
+
+	user, err := db.CreateUser("testuser", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := db.CreatePage("dragons", "Dragons", "one", user.ID, nil, RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{"two", "three"} {
+		if err := db.UpdatePage(page.ID, "Dragons", content, user.ID, nil, RevisionMeta{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revisions, err := db.ListRevisions(page.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	three, two, one := revisions[0], revisions[1], revisions[2]
+
+	// Backdate "two" before "one", as an import of older history might:
+	// revisions follow their timestamps, not their IDs
+	if _, err := db.Exec("UPDATE revisions SET created_at = ? WHERE id = ?", one.CreatedAt.Add(-time.Hour), two.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		rev  *Revision
+		want string
+	}{
+		{three, "one"},
+		{one, "two"},
+	} {
+		prev, err := db.GetPreviousRevision(tt.rev)
+		if err != nil {
+			t.Fatalf("GetPreviousRevision(%q): %v", tt.rev.Content, err)
+		}
+		if prev.Content != tt.want {
+			t.Errorf("GetPreviousRevision(%q) = %q, want %q", tt.rev.Content, prev.Content, tt.want)
+		}
+	}
+	if _, err := db.GetPreviousRevision(two); err != ErrNotFound {
+		t.Errorf("GetPreviousRevision(oldest) error = %v, want ErrNotFound", err)
+	}
+}
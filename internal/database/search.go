@@ -23,7 +23,7 @@ func (db *DB) Search(query string, limit int) ([]*SearchResult, error) {
 		SELECT p.slug, p.title, COALESCE(snippet(pages_fts, 1, '<mark>', '</mark>', '...', 32), '') as snippet
 		FROM pages_fts
 		JOIN pages p ON pages_fts.rowid = p.id
-		WHERE pages_fts MATCH ? AND p.deleted_at IS NULL
+		WHERE pages_fts MATCH ? AND p.deleted_at IS NULL AND p.sealed = 0
 		ORDER BY rank
 		LIMIT ?
 	`, sanitized, limit)
@@ -43,6 +43,36 @@ func (db *DB) Search(query string, limit int) ([]*SearchResult, error) {
 	return results, rows.Err()
 }
 
+// RebuildSearchIndex repopulates the search index from the current revision
+// of every page that isn't deleted, and returns how many pages it indexed.
+func (db *DB) RebuildSearchIndex() (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM pages_fts"); err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(`
+		INSERT INTO pages_fts (rowid, title, content)
+		SELECT p.id, p.title, r.content
+		FROM pages p
+		JOIN revisions r ON r.id = (SELECT id FROM revisions WHERE page_id = p.id ORDER BY created_at DESC, id DESC LIMIT 1)
+		WHERE p.deleted_at IS NULL
+	`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), tx.Commit()
+}
+
 // sanitizeFTSQuery prepares a query string for FTS5.
 // Supports:
 //   - Simple words: "dragon" matches "dragon", "dragons", "dragonfly" (with stemming)
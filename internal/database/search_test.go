@@ -27,19 +27,19 @@ func TestSearch(t *testing.T) {
 	}
 
 	// Create some test pages
-	page1, err := db.CreatePage("the-first-age", "The First Age", "This is content about the first age of the world.", user.ID)
+	page1, err := db.CreatePage("the-first-age", "The First Age", "This is content about the first age of the world.", user.ID, nil, RevisionMeta{})
 	if err != nil {
 		t.Fatalf("Failed to create page1: %v", err)
 	}
 	t.Logf("Created page1: ID=%d, slug=%s", page1.ID, page1.Slug)
 
-	page2, err := db.CreatePage("dragons", "Dragons", "Dragons are mythical creatures that breathe fire.", user.ID)
+	page2, err := db.CreatePage("dragons", "Dragons", "Dragons are mythical creatures that breathe fire.", user.ID, nil, RevisionMeta{})
 	if err != nil {
 		t.Fatalf("Failed to create page2: %v", err)
 	}
 	t.Logf("Created page2: ID=%d, slug=%s", page2.ID, page2.Slug)
 
-	page3, err := db.CreatePage("the-war", "The War", "The great war happened in the first age.", user.ID)
+	page3, err := db.CreatePage("the-war", "The War", "The great war happened in the first age.", user.ID, nil, RevisionMeta{})
 	if err != nil {
 		t.Fatalf("Failed to create page3: %v", err)
 	}
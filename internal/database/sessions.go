@@ -74,10 +74,14 @@ func (db *DB) DeleteUserSessions(userID int64) error {
 	return err
 }
 
-// CleanExpiredSessions removes all expired sessions.
-func (db *DB) CleanExpiredSessions() error {
-	_, err := db.Exec("DELETE FROM sessions WHERE expires_at < ?", time.Now())
-	return err
+// CleanExpiredSessions removes all expired sessions and returns how many
+// were removed.
+func (db *DB) CleanExpiredSessions() (int64, error) {
+	result, err := db.Exec("DELETE FROM sessions WHERE expires_at < ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 // ExtendSession updates the expiration time.
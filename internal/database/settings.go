@@ -1,6 +1,10 @@
 package database
 
-import "database/sql"
+import (
+	"database/sql"
+	"strconv"
+	"time"
+)
 
 // GetSetting retrieves a setting value by key.
 func (db *DB) GetSetting(key string) (string, error) {
@@ -68,6 +72,47 @@ func (db *DB) RegistrationCode() (string, error) {
 	return db.GetSetting("registration_code")
 }
 
+// ClaimDuration returns how long a claim on a phantom lasts.
+func (db *DB) ClaimDuration() (time.Duration, error) {
+	val, err := db.GetSetting("claim_duration_hours")
+	if err != nil {
+		return 0, err
+	}
+	hours, err := strconv.Atoi(val)
+	if err != nil || hours <= 0 {
+		return 48 * time.Hour, nil
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+// BackupInterval returns how often scheduled backups are taken, or zero if
+// they are off.
+func (db *DB) BackupInterval() (time.Duration, error) {
+	val, err := db.GetSetting("backup_interval_hours")
+	if err != nil {
+		return 0, err
+	}
+	hours, err := strconv.Atoi(val)
+	if err != nil || hours <= 0 {
+		return 0, nil
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+// BackupKeep returns how many backups to keep before rotating out the
+// oldest.
+func (db *DB) BackupKeep() (int, error) {
+	val, err := db.GetSetting("backup_keep")
+	if err != nil {
+		return 0, err
+	}
+	keep, err := strconv.Atoi(val)
+	if err != nil || keep <= 0 {
+		return 7, nil
+	}
+	return keep, nil
+}
+
 // WikiTitle returns the wiki title for display.
 func (db *DB) WikiTitle() (string, error) {
 	return db.GetSetting("wiki_title")
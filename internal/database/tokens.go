@@ -0,0 +1,156 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// API token scopes, from least to most access.
+const (
+	ScopeRead  = "read"  // GET requests only
+	ScopeWrite = "write" // anything the user can do, except as an admin
+	ScopeAdmin = "admin" // everything, including admin powers (admins only)
+)
+
+// apiTokenPrefix marks secrets as Lexicon API tokens, so they're easy to
+// recognize if they leak into a log or a repository.
+const apiTokenPrefix = "lex_"
+
+// APIToken is a personal token for scripts. Only a hash of its secret is
+// stored; the secret itself is shown once, when the token is created.
+type APIToken struct {
+	ID         int64
+	UserID     int64
+	Name       string
+	Scope      string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// ValidScope reports whether scope names an API token scope.
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeWrite || scope == ScopeAdmin
+}
+
+// Expired reports whether the token can no longer be used.
+func (t *APIToken) Expired() bool {
+	return t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now())
+}
+
+// CanWrite reports whether the token may make changes.
+func (t *APIToken) CanWrite() bool {
+	return t.Scope == ScopeWrite || t.Scope == ScopeAdmin
+}
+
+const apiTokenColumns = "id, user_id, name, scope, expires_at, last_used_at, created_at"
+
+func scanAPIToken(row interface{ Scan(...any) error }) (*APIToken, error) {
+	t := &APIToken{}
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+	return t, err
+}
+
+// CreateAPIToken issues a token for a user and returns it with its secret.
+// A nil expiresAt means the token never expires.
+func (db *DB) CreateAPIToken(userID int64, name, scope string, expiresAt *time.Time) (*APIToken, string, error) {
+	if !ValidScope(scope) {
+		return nil, "", fmt.Errorf("invalid scope %q", scope)
+	}
+
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, "", err
+	}
+	secret := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(bytes)
+
+	now := time.Now()
+	result, err := db.Exec(`
+		INSERT INTO api_tokens (user_id, name, token_hash, scope, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, name, hashAPIToken(secret), scope, expiresAt, now)
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &APIToken{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		Scope:     scope,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}, secret, nil
+}
+
+// GetAPITokenBySecret retrieves the unexpired token with the given secret.
+func (db *DB) GetAPITokenBySecret(secret string) (*APIToken, error) {
+	if secret == "" {
+		return nil, ErrNotFound
+	}
+	t, err := scanAPIToken(db.QueryRow(`
+		SELECT `+apiTokenColumns+` FROM api_tokens
+		WHERE token_hash = ? AND (expires_at IS NULL OR expires_at > ?)
+	`, hashAPIToken(secret), time.Now()))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// TouchAPIToken records that a token was just used.
+func (db *DB) TouchAPIToken(id int64) error {
+	_, err := db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", time.Now(), id)
+	return err
+}
+
+// ListAPITokens returns a user's tokens, newest first, including expired ones.
+func (db *DB) ListAPITokens(userID int64) ([]*APIToken, error) {
+	rows, err := db.Query(`
+		SELECT `+apiTokenColumns+` FROM api_tokens
+		WHERE user_id = ? ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes one of a user's tokens.
+func (db *DB) DeleteAPIToken(id, userID int64) error {
+	result, err := db.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPITokens(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := db.CreateUser("bob", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, secret, err := db.CreateAPIToken(alice.ID, "backup script", ScopeRead, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, apiTokenPrefix) {
+		t.Errorf("secret = %q, want prefix %q", secret, apiTokenPrefix)
+	}
+
+	// Only the hash is stored
+	var stored string
+	if err := db.QueryRow("SELECT token_hash FROM api_tokens WHERE id = ?", token.ID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored == secret || strings.Contains(stored, secret) {
+		t.Error("token secret is stored in the clear")
+	}
+
+	got, err := db.GetAPITokenBySecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != token.ID || got.UserID != alice.ID || got.Scope != ScopeRead || got.LastUsedAt != nil {
+		t.Errorf("GetAPITokenBySecret = %+v", got)
+	}
+	if _, err := db.GetAPITokenBySecret(secret + "x"); err != ErrNotFound {
+		t.Errorf("wrong secret: error = %v, want ErrNotFound", err)
+	}
+
+	if err := db.TouchAPIToken(token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetAPITokenBySecret(secret); got == nil || got.LastUsedAt == nil {
+		t.Error("TouchAPIToken didn't record the last use")
+	}
+
+	// An expired token no longer authenticates, but is still listed
+	past := time.Now().Add(-time.Minute)
+	_, expiredSecret, err := db.CreateAPIToken(alice.ID, "old", ScopeWrite, &past)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetAPITokenBySecret(expiredSecret); err != ErrNotFound {
+		t.Errorf("expired token: error = %v, want ErrNotFound", err)
+	}
+	tokens, err := db.ListAPITokens(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || !tokens[0].Expired() || tokens[1].Expired() {
+		t.Errorf("ListAPITokens = %+v", tokens)
+	}
+
+	if _, _, err := db.CreateAPIToken(alice.ID, "bad", "superuser", nil); err == nil {
+		t.Error("CreateAPIToken accepted an unknown scope")
+	}
+
+	// Users can only revoke their own tokens
+	if err := db.DeleteAPIToken(token.ID, bob.ID); err != ErrNotFound {
+		t.Errorf("DeleteAPIToken by another user: error = %v, want ErrNotFound", err)
+	}
+	if err := db.DeleteAPIToken(token.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetAPITokenBySecret(secret); err != ErrNotFound {
+		t.Errorf("revoked token: error = %v, want ErrNotFound", err)
+	}
+}
@@ -147,6 +147,26 @@ func (db *DB) UpdatePassword(userID int64, currentPassword, newPassword string)
 	return err
 }
 
+// SetPassword replaces a user's password without checking the old one, for
+// admins recovering an account.
+func (db *DB) SetPassword(userID int64, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec(`
+		UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?
+	`, string(hash), time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // DeleteUser removes a user.
 func (db *DB) DeleteUser(userID int64) error {
 	_, err := db.Exec("DELETE FROM users WHERE id = ?", userID)
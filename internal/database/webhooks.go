@@ -0,0 +1,281 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNoSecret is returned when a webhook would have no secret, which would
+// let anyone forge its signatures.
+var ErrNoSecret = errors.New("webhook secret is required")
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a URL notified of wiki events.
+type Webhook struct {
+	ID        int64
+	URL       string
+	Secret    string // key for the HMAC signature of each delivery
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+}
+
+// Subscribes reports whether the webhook wants the given event.
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one notification of one webhook, queued or sent.
+type WebhookDelivery struct {
+	ID             int64
+	WebhookID      int64
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttemptAt  *time.Time // when a pending delivery is tried next
+	LastAttemptAt  *time.Time
+	ResponseStatus int    // HTTP status of the last attempt, 0 if none
+	Response       string // start of the last response body, or the error
+	CreatedAt      time.Time
+
+	// Joined fields
+	URL    string
+	Secret string
+}
+
+const webhookColumns = "id, url, secret, events, active, created_at"
+
+func scanWebhook(row interface{ Scan(...any) error }) (*Webhook, error) {
+	w := &Webhook{}
+	var events string
+	if err := row.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.Active, &w.CreatedAt); err != nil {
+		return nil, err
+	}
+	if events != "" {
+		w.Events = strings.Split(events, ",")
+	}
+	return w, nil
+}
+
+// CreateWebhook registers a webhook for the given events.
+func (db *DB) CreateWebhook(url, secret string, events []string) (*Webhook, error) {
+	if secret == "" {
+		return nil, ErrNoSecret
+	}
+	now := time.Now()
+	result, err := db.Exec(`
+		INSERT INTO webhooks (url, secret, events, active, created_at) VALUES (?, ?, ?, 1, ?)
+	`, url, secret, strings.Join(events, ","), now)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &Webhook{ID: id, URL: url, Secret: secret, Events: events, Active: true, CreatedAt: now}, nil
+}
+
+// UpdateWebhook changes a webhook's settings. Queued deliveries keep the
+// payload they were created with but go to the new URL.
+func (db *DB) UpdateWebhook(w *Webhook) error {
+	if w.Secret == "" {
+		return ErrNoSecret
+	}
+	result, err := db.Exec(`
+		UPDATE webhooks SET url = ?, secret = ?, events = ?, active = ? WHERE id = ?
+	`, w.URL, w.Secret, strings.Join(w.Events, ","), w.Active, w.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID.
+func (db *DB) GetWebhook(id int64) (*Webhook, error) {
+	w, err := scanWebhook(db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ListWebhooks returns all webhooks, oldest first.
+func (db *DB) ListWebhooks() ([]*Webhook, error) {
+	rows, err := db.Query("SELECT " + webhookColumns + " FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []*Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, w)
+	}
+	return hooks, rows.Err()
+}
+
+// DeleteWebhook removes a webhook along with its deliveries.
+func (db *DB) DeleteWebhook(id int64) error {
+	result, err := db.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// QueueWebhookDelivery queues a payload for one webhook, due immediately.
+func (db *DB) QueueWebhookDelivery(webhookID int64, event string, payload []byte) error {
+	now := time.Now()
+	_, err := db.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, webhookID, event, string(payload), DeliveryPending, now, now)
+	return err
+}
+
+// QueueWebhookDeliveries queues a payload for every active webhook
+// subscribed to the event, and returns how many were queued.
+func (db *DB) QueueWebhookDeliveries(event string, payload []byte) (int, error) {
+	hooks, err := db.ListWebhooks()
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, w := range hooks {
+		if !w.Active || !w.Subscribes(event) {
+			continue
+		}
+		if err := db.QueueWebhookDelivery(w.ID, event, payload); err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+const deliveryColumns = `d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+	d.last_attempt_at, d.response_status, d.response, d.created_at, w.url, w.secret`
+
+func scanDeliveries(rows *sql.Rows) ([]*WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		d := &WebhookDelivery{}
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastAttemptAt, &d.ResponseStatus, &d.Response, &d.CreatedAt, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// DueWebhookDeliveries returns pending deliveries of active webhooks whose
+// next attempt is due, oldest first.
+func (db *DB) DueWebhookDeliveries(limit int) ([]*WebhookDelivery, error) {
+	rows, err := db.Query(`
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		JOIN webhooks w ON d.webhook_id = w.id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND w.active = 1
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT ?
+	`, DeliveryPending, time.Now(), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+// ListWebhookDeliveries returns the latest deliveries, newest first. A
+// webhookID of 0 lists deliveries of every webhook.
+func (db *DB) ListWebhookDeliveries(webhookID int64, limit int) ([]*WebhookDelivery, error) {
+	rows, err := db.Query(`
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		JOIN webhooks w ON d.webhook_id = w.id
+		WHERE ? = 0 OR d.webhook_id = ?
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT ?
+	`, webhookID, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+// RecordWebhookAttempt stores the outcome of trying a delivery. A failed
+// attempt is retried at next, or gives up for good if next is nil.
+func (db *DB) RecordWebhookAttempt(id int64, ok bool, responseStatus int, response string, next *time.Time) error {
+	status := DeliveryDelivered
+	if !ok {
+		status = DeliveryPending
+		if next == nil {
+			status = DeliveryFailed
+		}
+	}
+	_, err := db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, last_attempt_at = ?, next_attempt_at = ?,
+			response_status = ?, response = ?
+		WHERE id = ?
+	`, status, time.Now(), next, responseStatus, response, id)
+	return err
+}
+
+// RetryWebhookDelivery queues a delivery to be sent again right away, with
+// a fresh set of attempts.
+func (db *DB) RetryWebhookDelivery(id int64) error {
+	result, err := db.Exec(`
+		UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ?
+	`, DeliveryPending, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CleanWebhookDeliveries deletes finished deliveries created before cutoff.
+func (db *DB) CleanWebhookDeliveries(cutoff time.Time) (int64, error) {
+	result, err := db.Exec(`
+		DELETE FROM webhook_deliveries WHERE status != ? AND created_at < ?
+	`, DeliveryPending, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
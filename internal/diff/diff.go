@@ -0,0 +1,354 @@
+// Package diff computes line- and word-level differences between texts.
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kind identifies whether a piece of text was kept, added or removed.
+type Kind int
+
+const (
+	Equal Kind = iota
+	Insert
+	Delete
+)
+
+// String returns a lowercase name for the kind, used in CSS class names.
+func (k Kind) String() string {
+	switch k {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// maxEditDistance bounds the work done by the Myers algorithm. Inputs that
+// differ by more edits than this are reported as a wholesale replacement.
+const maxEditDistance = 2000
+
+// Segment is a run of text that was kept, added or removed.
+type Segment struct {
+	Kind Kind
+	Text string
+}
+
+// Line is one line of a line-level diff. Line numbers are 1-based and zero
+// when the line does not exist on that side.
+type Line struct {
+	Kind     Kind
+	OldNum   int
+	NewNum   int
+	Segments []Segment
+}
+
+// Hunk is a group of changed lines with surrounding context.
+type Hunk struct {
+	Lines []Line
+}
+
+// edit is a single step of an edit script. A indexes the old sequence
+// (Equal, Delete) and B the new sequence (Equal, Insert).
+type edit struct {
+	Kind Kind
+	A, B int
+}
+
+// Lines computes a line-level diff between a and b. Changed lines that
+// replace one another carry word-level segments.
+func Lines(a, b string) []Line {
+	oldLines := SplitLines(a)
+	newLines := SplitLines(b)
+	edits := diffTokens(oldLines, newLines)
+
+	var result []Line
+	var deleted, inserted []int
+
+	flush := func() {
+		paired := len(deleted)
+		if len(inserted) < paired {
+			paired = len(inserted)
+		}
+		var added []Line
+		for i, idx := range deleted {
+			line := Line{Kind: Delete, OldNum: idx + 1}
+			if i < paired {
+				oldSegs, newSegs := Words(oldLines[idx], newLines[inserted[i]])
+				line.Segments = oldSegs
+				added = append(added, Line{Kind: Insert, NewNum: inserted[i] + 1, Segments: newSegs})
+			} else {
+				line.Segments = []Segment{{Kind: Delete, Text: oldLines[idx]}}
+			}
+			result = append(result, line)
+		}
+		for _, idx := range inserted[paired:] {
+			added = append(added, Line{
+				Kind:     Insert,
+				NewNum:   idx + 1,
+				Segments: []Segment{{Kind: Insert, Text: newLines[idx]}},
+			})
+		}
+		result = append(result, added...)
+		deleted, inserted = nil, nil
+	}
+
+	for _, e := range edits {
+		switch e.Kind {
+		case Delete:
+			deleted = append(deleted, e.A)
+		case Insert:
+			inserted = append(inserted, e.B)
+		default:
+			flush()
+			result = append(result, Line{
+				Kind:     Equal,
+				OldNum:   e.A + 1,
+				NewNum:   e.B + 1,
+				Segments: []Segment{{Kind: Equal, Text: oldLines[e.A]}},
+			})
+		}
+	}
+	flush()
+
+	return result
+}
+
+// Words diffs two strings word by word and returns the segments for the old
+// text (Equal and Delete) and the new text (Equal and Insert).
+func Words(a, b string) (oldSegs, newSegs []Segment) {
+	oldWords := tokenize(a)
+	newWords := tokenize(b)
+
+	for _, e := range diffTokens(oldWords, newWords) {
+		switch e.Kind {
+		case Equal:
+			oldSegs = appendSegment(oldSegs, Equal, oldWords[e.A])
+			newSegs = appendSegment(newSegs, Equal, newWords[e.B])
+		case Delete:
+			oldSegs = appendSegment(oldSegs, Delete, oldWords[e.A])
+		case Insert:
+			newSegs = appendSegment(newSegs, Insert, newWords[e.B])
+		}
+	}
+	return oldSegs, newSegs
+}
+
+// Hunks groups changed lines with up to context unchanged lines on either
+// side. Returns nil if nothing changed.
+func Hunks(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+	start, end := -1, -1
+
+	for i, line := range lines {
+		if line.Kind == Equal {
+			continue
+		}
+		lo := max(i-context, 0)
+		hi := min(i+context+1, len(lines))
+		if start >= 0 && lo <= end {
+			end = hi
+			continue
+		}
+		if start >= 0 {
+			hunks = append(hunks, Hunk{Lines: lines[start:end]})
+		}
+		start, end = lo, hi
+	}
+	if start >= 0 {
+		hunks = append(hunks, Hunk{Lines: lines[start:end]})
+	}
+
+	return hunks
+}
+
+// Stats counts added and removed lines.
+func Stats(lines []Line) (added, removed int) {
+	for _, line := range lines {
+		switch line.Kind {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// SplitLines splits text into lines, normalizing line endings. A trailing
+// newline does not produce an extra empty line.
+func SplitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// tokenize splits text into runs of letters and digits, runs of whitespace,
+// and single punctuation characters.
+func tokenize(s string) []string {
+	var tokens []string
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch {
+		case isWordRune(runes[i]):
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+		case unicode.IsSpace(runes[i]):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func appendSegment(segs []Segment, kind Kind, text string) []Segment {
+	if n := len(segs); n > 0 && segs[n-1].Kind == kind {
+		segs[n-1].Text += text
+		return segs
+	}
+	return append(segs, Segment{Kind: kind, Text: text})
+}
+
+// diffTokens returns an edit script transforming a into b.
+func diffTokens(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []edit
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, edit{Kind: Equal, A: i, B: i})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := 0; i < suffix; i++ {
+		edits = append(edits, edit{Kind: Equal, A: len(a) - suffix + i, B: len(b) - suffix + i})
+	}
+
+	return edits
+}
+
+// snapshot holds the furthest-reaching x for diagonals lo..lo+len(x)-1.
+type snapshot struct {
+	lo int
+	x  []int
+}
+
+func (s snapshot) get(k int) int {
+	return s.x[k-s.lo]
+}
+
+// myers implements the Myers O(ND) difference algorithm. Indexes in the
+// returned edits are shifted by aOff and bOff.
+func myers(a, b []string, aOff, bOff int) []edit {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil
+	}
+
+	limit := n + m
+	v := make([]int, 2*limit+3)
+	offset := limit + 1
+	var trace []snapshot
+
+	for d := 0; d <= limit; d++ {
+		if d > maxEditDistance {
+			return replaceAll(n, m, aOff, bOff)
+		}
+
+		snap := snapshot{lo: -d - 1, x: make([]int, 2*d+3)}
+		copy(snap.x, v[offset-d-1:offset+d+2])
+		trace = append(trace, snap)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, n, m, aOff, bOff)
+			}
+		}
+	}
+
+	return replaceAll(n, m, aOff, bOff)
+}
+
+func backtrack(trace []snapshot, n, m, aOff, bOff int) []edit {
+	var edits []edit
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		snap := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && snap.get(k-1) < snap.get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := snap.get(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{Kind: Equal, A: aOff + x - 1, B: bOff + y - 1})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{Kind: Insert, A: -1, B: bOff + y - 1})
+			} else {
+				edits = append(edits, edit{Kind: Delete, A: aOff + x - 1, B: -1})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func replaceAll(n, m, aOff, bOff int) []edit {
+	edits := make([]edit, 0, n+m)
+	for i := 0; i < n; i++ {
+		edits = append(edits, edit{Kind: Delete, A: aOff + i, B: -1})
+	}
+	for i := 0; i < m; i++ {
+		edits = append(edits, edit{Kind: Insert, A: -1, B: bOff + i})
+	}
+	return edits
+}
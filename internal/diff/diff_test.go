@@ -0,0 +1,128 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// apply rebuilds both sides of a line diff.
+func apply(lines []Line) (oldText, newText string) {
+	var oldLines, newLines []string
+	for _, line := range lines {
+		var oldB, newB strings.Builder
+		for _, seg := range line.Segments {
+			if seg.Kind != Insert {
+				oldB.WriteString(seg.Text)
+			}
+			if seg.Kind != Delete {
+				newB.WriteString(seg.Text)
+			}
+		}
+		if line.Kind != Insert {
+			oldLines = append(oldLines, oldB.String())
+		}
+		if line.Kind != Delete {
+			newLines = append(newLines, newB.String())
+		}
+	}
+	return strings.Join(oldLines, "\n"), strings.Join(newLines, "\n")
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name        string
+		old, new    string
+		wantAdded   int
+		wantRemoved int
+	}{
+		{"identical", "a\nb\nc", "a\nb\nc", 0, 0},
+		{"empty to text", "", "a\nb", 2, 0},
+		{"text to empty", "a\nb", "", 0, 2},
+		{"insert middle", "a\nc", "a\nb\nc", 1, 0},
+		{"delete middle", "a\nb\nc", "a\nc", 0, 1},
+		{"change line", "a\nthe old line\nc", "a\nthe new line\nc", 1, 1},
+		{"trailing newline ignored", "a\nb\n", "a\nb", 0, 0},
+		{"crlf normalized", "a\r\nb", "a\nb", 0, 0},
+		{"reorder", "a\nb\nc\nd", "d\nb\nc\na", 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := Lines(tt.old, tt.new)
+
+			added, removed := Stats(lines)
+			if added != tt.wantAdded || removed != tt.wantRemoved {
+				t.Errorf("Stats = +%d -%d, want +%d -%d", added, removed, tt.wantAdded, tt.wantRemoved)
+			}
+
+			gotOld, gotNew := apply(lines)
+			if want := strings.Join(SplitLines(tt.old), "\n"); gotOld != want {
+				t.Errorf("old side = %q, want %q", gotOld, want)
+			}
+			if want := strings.Join(SplitLines(tt.new), "\n"); gotNew != want {
+				t.Errorf("new side = %q, want %q", gotNew, want)
+			}
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	oldSegs, newSegs := Words("The red king ruled.", "The crimson king ruled!")
+
+	wantOld := []Segment{
+		{Equal, "The "},
+		{Delete, "red"},
+		{Equal, " king ruled"},
+		{Delete, "."},
+	}
+	wantNew := []Segment{
+		{Equal, "The "},
+		{Insert, "crimson"},
+		{Equal, " king ruled"},
+		{Insert, "!"},
+	}
+
+	if len(oldSegs) != len(wantOld) {
+		t.Fatalf("old segments = %v, want %v", oldSegs, wantOld)
+	}
+	for i := range wantOld {
+		if oldSegs[i] != wantOld[i] {
+			t.Errorf("old segment %d = %v, want %v", i, oldSegs[i], wantOld[i])
+		}
+	}
+	if len(newSegs) != len(wantNew) {
+		t.Fatalf("new segments = %v, want %v", newSegs, wantNew)
+	}
+	for i := range wantNew {
+		if newSegs[i] != wantNew[i] {
+			t.Errorf("new segment %d = %v, want %v", i, newSegs[i], wantNew[i])
+		}
+	}
+}
+
+func TestHunks(t *testing.T) {
+	var oldLines, newLines []string
+	for i := 0; i < 20; i++ {
+		line := strings.Repeat("x", i+1)
+		oldLines = append(oldLines, line)
+		if i == 2 || i == 17 {
+			line += " changed"
+		}
+		newLines = append(newLines, line)
+	}
+
+	hunks := Hunks(Lines(strings.Join(oldLines, "\n"), strings.Join(newLines, "\n")), 2)
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+	if got := len(hunks[0].Lines); got != 6 {
+		t.Errorf("first hunk has %d lines, want 6", got)
+	}
+	if got := hunks[1].Lines[0].OldNum; got != 16 {
+		t.Errorf("second hunk starts at old line %d, want 16", got)
+	}
+
+	if hunks := Hunks(Lines("same", "same"), 3); hunks != nil {
+		t.Errorf("Hunks of identical text = %v, want nil", hunks)
+	}
+}
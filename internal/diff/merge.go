@@ -0,0 +1,140 @@
+package diff
+
+import "strings"
+
+// change replaces base[start:end] with lines.
+type change struct {
+	start, end int
+	lines      []string
+}
+
+// Merge3 performs a line-based three-way merge of two texts derived from a
+// common base. It returns the merged text and true, or "" and false if both
+// sides changed the same region differently.
+func Merge3(base, ours, theirs string) (string, bool) {
+	baseLines := SplitLines(base)
+	oursLines := SplitLines(ours)
+	theirsLines := SplitLines(theirs)
+
+	a := changesFrom(baseLines, oursLines)
+	b := changesFrom(baseLines, theirsLines)
+
+	var merged []string
+	cursor := 0
+
+	for len(a) > 0 || len(b) > 0 {
+		// Start a region with whichever change comes first, then absorb every
+		// change from either side that overlaps it.
+		var regionA, regionB []change
+		var start, end int
+		if len(b) == 0 || (len(a) > 0 && a[0].start <= b[0].start) {
+			start, end = a[0].start, a[0].end
+			regionA, a = a[:1], a[1:]
+		} else {
+			start, end = b[0].start, b[0].end
+			regionB, b = b[:1], b[1:]
+		}
+		for {
+			if len(a) > 0 && overlaps(a[0], start, end) {
+				end = max(end, a[0].end)
+				regionA, a = append(regionA, a[0]), a[1:]
+				continue
+			}
+			if len(b) > 0 && overlaps(b[0], start, end) {
+				end = max(end, b[0].end)
+				regionB, b = append(regionB, b[0]), b[1:]
+				continue
+			}
+			break
+		}
+
+		merged = append(merged, baseLines[cursor:start]...)
+		cursor = end
+
+		switch {
+		case len(regionB) == 0:
+			merged = append(merged, applyChanges(baseLines, start, end, regionA)...)
+		case len(regionA) == 0:
+			merged = append(merged, applyChanges(baseLines, start, end, regionB)...)
+		default:
+			oursRegion := applyChanges(baseLines, start, end, regionA)
+			theirsRegion := applyChanges(baseLines, start, end, regionB)
+			if !equalLines(oursRegion, theirsRegion) {
+				return "", false
+			}
+			merged = append(merged, oursRegion...)
+		}
+	}
+	merged = append(merged, baseLines[cursor:]...)
+
+	result := strings.Join(merged, "\n")
+	if result != "" && strings.HasSuffix(ours, "\n") {
+		result += "\n"
+	}
+	return result, true
+}
+
+// changesFrom converts the diff between base and other into a list of
+// replaced base ranges, in order.
+func changesFrom(base, other []string) []change {
+	var changes []change
+	var current *change
+	pos := 0
+
+	for _, e := range diffTokens(base, other) {
+		switch e.Kind {
+		case Equal:
+			if current != nil {
+				changes = append(changes, *current)
+				current = nil
+			}
+			pos++
+		case Delete:
+			if current == nil {
+				current = &change{start: pos, end: pos}
+			}
+			pos++
+			current.end = pos
+		case Insert:
+			if current == nil {
+				current = &change{start: pos, end: pos}
+			}
+			current.lines = append(current.lines, other[e.B])
+		}
+	}
+	if current != nil {
+		changes = append(changes, *current)
+	}
+
+	return changes
+}
+
+// overlaps reports whether c touches the base range [start, end). Two
+// insertions at the same position also overlap.
+func overlaps(c change, start, end int) bool {
+	return c.start == start || (c.start < end && start < c.end)
+}
+
+// applyChanges returns base[start:end] with the given changes applied.
+func applyChanges(base []string, start, end int, changes []change) []string {
+	var out []string
+	pos := start
+	for _, c := range changes {
+		out = append(out, base[pos:c.start]...)
+		out = append(out, c.lines...)
+		pos = c.end
+	}
+	return append(out, base[pos:end]...)
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
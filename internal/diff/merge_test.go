@@ -0,0 +1,94 @@
+package diff
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\nfive\n"
+
+	tests := []struct {
+		name         string
+		ours, theirs string
+		want         string
+		wantOK       bool
+	}{
+		{
+			name:   "only ours changed",
+			ours:   "one\nTWO\nthree\nfour\nfive\n",
+			theirs: base,
+			want:   "one\nTWO\nthree\nfour\nfive\n",
+			wantOK: true,
+		},
+		{
+			name:   "only theirs changed",
+			ours:   base,
+			theirs: "one\ntwo\nthree\nfour\nFIVE\n",
+			want:   "one\ntwo\nthree\nfour\nFIVE\n",
+			wantOK: true,
+		},
+		{
+			name:   "separate lines changed",
+			ours:   "one\nTWO\nthree\nfour\nfive\n",
+			theirs: "one\ntwo\nthree\nfour\nFIVE\n",
+			want:   "one\nTWO\nthree\nfour\nFIVE\n",
+			wantOK: true,
+		},
+		{
+			name:   "adjacent lines changed",
+			ours:   "one\nTWO\nthree\nfour\nfive\n",
+			theirs: "one\ntwo\nTHREE\nfour\nfive\n",
+			want:   "one\nTWO\nTHREE\nfour\nfive\n",
+			wantOK: true,
+		},
+		{
+			name:   "insertions at different places",
+			ours:   "zero\none\ntwo\nthree\nfour\nfive\n",
+			theirs: "one\ntwo\nthree\nfour\nfive\nsix\n",
+			want:   "zero\none\ntwo\nthree\nfour\nfive\nsix\n",
+			wantOK: true,
+		},
+		{
+			name:   "same change on both sides",
+			ours:   "one\ntwo\n3\nfour\nfive\n",
+			theirs: "one\ntwo\n3\nfour\nfive\n",
+			want:   "one\ntwo\n3\nfour\nfive\n",
+			wantOK: true,
+		},
+		{
+			name:   "deletion and unrelated edit",
+			ours:   "one\nthree\nfour\nfive\n",
+			theirs: "one\ntwo\nthree\nfour\nFIVE\n",
+			want:   "one\nthree\nfour\nFIVE\n",
+			wantOK: true,
+		},
+		{
+			name:   "same line changed differently",
+			ours:   "one\ntwo\nthree (ours)\nfour\nfive\n",
+			theirs: "one\ntwo\nthree (theirs)\nfour\nfive\n",
+			wantOK: false,
+		},
+		{
+			name:   "insertions at the same place",
+			ours:   "one\ntwo\nours\nthree\nfour\nfive\n",
+			theirs: "one\ntwo\ntheirs\nthree\nfour\nfive\n",
+			wantOK: false,
+		},
+		{
+			name:   "edit inside deleted range",
+			ours:   "one\nfive\n",
+			theirs: "one\ntwo\nTHREE\nfour\nfive\n",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Merge3(base, tt.ours, tt.theirs)
+			if ok != tt.wantOK {
+				t.Fatalf("Merge3 ok = %v, want %v (merged %q)", ok, tt.wantOK, got)
+			}
+			if ok && got != tt.want {
+				t.Errorf("Merge3 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Package feed renders Atom and RSS 2.0 syndication feeds.
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Feed is a format-independent description of a syndication feed.
+type Feed struct {
+	Title   string
+	Link    string // HTML page the feed mirrors
+	Self    string // URL of the feed itself
+	Updated time.Time
+	Entries []Entry
+}
+
+// Entry is a single feed item.
+type Entry struct {
+	ID      string // stable unique URI; defaults to Link
+	Title   string
+	Link    string
+	Author  string
+	Updated time.Time
+	Summary string // plain text
+	Content string // HTML
+}
+
+// Content types for the supported formats.
+const (
+	AtomContentType = "application/atom+xml; charset=utf-8"
+	RSSContentType  = "application/rss+xml; charset=utf-8"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string       `xml:"title"`
+	ID      string       `xml:"id"`
+	Updated string       `xml:"updated"`
+	Link    atomLink     `xml:"link"`
+	Author  *atomAuthor  `xml:"author,omitempty"`
+	Summary string       `xml:"summary,omitempty"`
+	Content *atomContent `xml:"content,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// WriteAtom writes the feed as an Atom 1.0 document.
+func (f *Feed) WriteAtom(w io.Writer) error {
+	af := atomFeed{
+		Title:   f.Title,
+		ID:      f.Self,
+		Updated: f.updated().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	for _, e := range f.Entries {
+		ae := atomEntry{
+			Title:   e.Title,
+			ID:      e.id(),
+			Updated: e.Updated.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: e.Link, Rel: "alternate", Type: "text/html"},
+			Summary: e.Summary,
+		}
+		if e.Author != "" {
+			ae.Author = &atomAuthor{Name: e.Author}
+		}
+		if e.Content != "" {
+			ae.Content = &atomContent{Type: "html", Body: e.Content}
+		}
+		af.Entries = append(af.Entries, ae)
+	}
+	return writeXML(w, af)
+}
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Author      string  `xml:"http://purl.org/dc/elements/1.1/ creator,omitempty"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// WriteRSS writes the feed as an RSS 2.0 document.
+func (f *Feed) WriteRSS(w io.Writer) error {
+	doc := rssDoc{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Title,
+			LastBuildDate: f.updated().UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, e := range f.Entries {
+		description := e.Content
+		if description == "" {
+			description = e.Summary
+		}
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			GUID:        rssGUID{IsPermaLink: e.ID == "", Value: e.id()},
+			Author:      e.Author,
+			PubDate:     e.Updated.UTC().Format(time.RFC1123Z),
+			Description: description,
+		})
+	}
+	return writeXML(w, doc)
+}
+
+// updated returns the feed's timestamp, falling back to its newest entry.
+func (f *Feed) updated() time.Time {
+	if !f.Updated.IsZero() {
+		return f.Updated
+	}
+	var latest time.Time
+	for _, e := range f.Entries {
+		if e.Updated.After(latest) {
+			latest = e.Updated
+		}
+	}
+	if latest.IsZero() {
+		return time.Now()
+	}
+	return latest
+}
+
+func (e *Entry) id() string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return e.Link
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
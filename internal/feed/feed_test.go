@@ -0,0 +1,74 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	return &Feed{
+		Title: "Recent changes",
+		Link:  "https://wiki.example.com/special/recent-changes",
+		Self:  "https://wiki.example.com/feeds/changes.atom",
+		Entries: []Entry{
+			{
+				ID:      "https://wiki.example.com/dragons/revision/2",
+				Title:   "Dragons",
+				Link:    "https://wiki.example.com/dragons",
+				Author:  "alice",
+				Updated: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+				Content: "<p>Dragons & <em>fire</em></p>",
+			},
+		},
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testFeed().WriteAtom(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed atomFeed
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("invalid Atom: %v\n%s", err, buf.String())
+	}
+	if parsed.Updated != "2024-03-01T12:00:00Z" {
+		t.Errorf("feed updated = %q, want newest entry time", parsed.Updated)
+	}
+	if len(parsed.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(parsed.Entries))
+	}
+	e := parsed.Entries[0]
+	if e.ID != "https://wiki.example.com/dragons/revision/2" || e.Author == nil || e.Author.Name != "alice" {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Content == nil || e.Content.Body != "<p>Dragons & <em>fire</em></p>" {
+		t.Errorf("content = %+v, want escaped HTML round-trip", e.Content)
+	}
+}
+
+func TestWriteRSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testFeed().WriteRSS(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed rssDoc
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("invalid RSS: %v\n%s", err, buf.String())
+	}
+	if len(parsed.Channel.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(parsed.Channel.Items))
+	}
+	item := parsed.Channel.Items[0]
+	if item.PubDate != "Fri, 01 Mar 2024 12:00:00 +0000" {
+		t.Errorf("pubDate = %q", item.PubDate)
+	}
+	if item.GUID.IsPermaLink || !strings.HasSuffix(item.GUID.Value, "/revision/2") {
+		t.Errorf("guid = %+v", item.GUID)
+	}
+}
@@ -3,6 +3,12 @@ package handler
 import (
 	"net/http"
 	"strconv"
+	"strings"
+
+	"lexicon/internal/markdown"
+	"lexicon/internal/middleware"
+	"lexicon/internal/rules"
+	"lexicon/internal/webhook"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -30,12 +36,44 @@ func (h *Handler) AdminSettings(w http.ResponseWriter, r *http.Request) {
 
 // AdminSaveSettings handles settings form submission.
 func (h *Handler) AdminSaveSettings(w http.ResponseWriter, r *http.Request) {
+	claimHours := strings.TrimSpace(r.FormValue("claim_duration_hours"))
+	if n, err := strconv.Atoi(claimHours); err != nil || n <= 0 {
+		h.AddFlash(r, "danger", "Claim duration must be a positive number of hours")
+		http.Redirect(w, r, "/admin/settings", http.StatusSeeOther)
+		return
+	}
+
+	minCitations, err1 := strconv.Atoi(strings.TrimSpace(r.FormValue(rules.SettingMinCitations)))
+	minPhantoms, err2 := strconv.Atoi(strings.TrimSpace(r.FormValue(rules.SettingMinPhantoms)))
+	if err1 != nil || err2 != nil || minCitations < 0 || minPhantoms < 0 {
+		h.AddFlash(r, "danger", "Minimum citations and phantoms must be zero or more")
+		http.Redirect(w, r, "/admin/settings", http.StatusSeeOther)
+		return
+	}
+
+	backupHours, err1 := strconv.Atoi(strings.TrimSpace(r.FormValue("backup_interval_hours")))
+	backupKeep, err2 := strconv.Atoi(strings.TrimSpace(r.FormValue("backup_keep")))
+	if err1 != nil || err2 != nil || backupHours < 0 || backupKeep <= 0 {
+		h.AddFlash(r, "danger", "Backup interval must be zero or more and backups to keep must be positive")
+		http.Redirect(w, r, "/admin/settings", http.StatusSeeOther)
+		return
+	}
+
 	// Update each setting
 	settings := map[string]string{
-		"wiki_title":           r.FormValue("wiki_title"),
-		"public_read_access":   boolToString(r.FormValue("public_read_access") == "true"),
-		"registration_enabled": boolToString(r.FormValue("registration_enabled") == "true"),
-		"registration_code":    r.FormValue("registration_code"),
+		"wiki_title":            r.FormValue("wiki_title"),
+		"public_read_access":    boolToString(r.FormValue("public_read_access") == "true"),
+		"registration_enabled":  boolToString(r.FormValue("registration_enabled") == "true"),
+		"registration_code":     r.FormValue("registration_code"),
+		"claim_duration_hours":  claimHours,
+		"seal_round_entries":    boolToString(r.FormValue("seal_round_entries") == "true"),
+		"backup_interval_hours": strconv.Itoa(backupHours),
+		"backup_keep":           strconv.Itoa(backupKeep),
+
+		rules.SettingNoSelfCitation: boolToString(r.FormValue(rules.SettingNoSelfCitation) == "true"),
+		rules.SettingMinCitations:   strconv.Itoa(minCitations),
+		rules.SettingMinPhantoms:    strconv.Itoa(minPhantoms),
+		rules.SettingNoOwnPhantom:   boolToString(r.FormValue(rules.SettingNoOwnPhantom) == "true"),
 	}
 
 	for key, value := range settings {
@@ -134,9 +172,22 @@ func (h *Handler) AdminRestorePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.DB.RestorePage(pageID); err != nil {
+	// Links are restored from the current revision, and cited pages that
+	// have gone since become phantoms again
+	var links []markdown.LinkInfo
+	var authorID int64
+	if rev, err := h.DB.GetCurrentRevision(pageID); err == nil {
+		links = h.Markdown.ExtractLinks(rev.Content)
+		authorID = rev.AuthorID
+	}
+
+	if err := h.DB.RestorePage(pageID, markdown.DatabaseLinks(links)); err != nil {
 		h.AddFlash(r, "danger", "Failed to restore page")
 	} else {
+		h.processWikiLinks(links, authorID, pageID)
+		if page, err := h.DB.GetPageByID(pageID); err == nil {
+			h.emitPageEvent(r, webhook.PageRestored, page, middleware.GetUser(r))
+		}
 		h.AddFlash(r, "success", "Page restored")
 	}
 
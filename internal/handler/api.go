@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"lexicon/internal/middleware"
+)
+
+const (
+	apiDefaultPerPage = 50
+	apiMaxPerPage     = 200
+	apiMaxBody        = 1 << 20
+)
+
+// apiResponse is the body of every successful API response.
+type apiResponse struct {
+	Data       any            `json:"data"`
+	Pagination *apiPagination `json:"pagination,omitempty"`
+}
+
+// apiPagination describes which slice of a list a response holds.
+type apiPagination struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// apiErrorBody is the body of every API error response.
+type apiErrorBody struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// APIAccess guards the JSON API: reading follows the public read access
+// setting, writing needs a signed-in user. Every response carries a fresh
+// CSRF token in X-CSRF-Token for the client's next write.
+func (h *Handler) APIAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-CSRF-Token", middleware.GetCSRFToken(r))
+
+		if middleware.GetUser(r) == nil {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				apiError(w, http.StatusUnauthorized, "unauthorized", "Sign in to make changes")
+				return
+			}
+			if public, err := h.DB.PublicReadAccess(); err != nil || !public {
+				apiError(w, http.StatusUnauthorized, "unauthorized", "Sign in to read this wiki")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// APINotFound answers requests for unknown API endpoints.
+func (h *Handler) APINotFound(w http.ResponseWriter, r *http.Request) {
+	apiError(w, http.StatusNotFound, "not_found", "No such endpoint")
+}
+
+// APIMethodNotAllowed answers requests with the wrong method.
+func (h *Handler) APIMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	apiError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+}
+
+// apiError writes an error object.
+func apiError(w http.ResponseWriter, status int, code, message string) {
+	apiErrorWithDetails(w, status, code, message, nil)
+}
+
+func apiErrorWithDetails(w http.ResponseWriter, status int, code, message string, details any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiErrorBody{Error: apiErrorDetail{Code: code, Message: message, Details: details}})
+}
+
+// apiJSON writes data with an ETag of its content. A GET whose
+// If-None-Match already has that ETag gets 304 Not Modified instead.
+func apiJSON(w http.ResponseWriter, r *http.Request, status int, resp apiResponse) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("API: failed to encode response: %v", err)
+		apiError(w, http.StatusInternalServerError, "internal_error", "Failed to encode response")
+		return
+	}
+
+	tag := etag(body)
+	w.Header().Set("ETag", tag)
+	if r.Method == http.MethodGet && status == http.StatusOK && etagMatches(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+	w.Write([]byte("\n"))
+}
+
+// apiETag returns the ETag apiJSON would send for resp.
+func apiETag(resp apiResponse) (string, error) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return "", err
+	}
+	return etag(body), nil
+}
+
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match or If-Match header lists tag.
+func etagMatches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeAPIBody reads a JSON request body into v, writing an error response
+// and returning false if it can't.
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(http.MaxBytesReader(w, r.Body, apiMaxBody)); err != nil {
+		apiError(w, http.StatusRequestEntityTooLarge, "too_large", "Request body is too large")
+		return false
+	}
+	if err := json.Unmarshal(buf.Bytes(), v); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// paginate returns the slice of items requested by the page and per_page
+// query parameters. It writes an error response and returns ok=false if
+// they are invalid.
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) (page []T, p *apiPagination, ok bool) {
+	p = &apiPagination{Page: 1, PerPage: apiDefaultPerPage, Total: len(items)}
+
+	query := r.URL.Query()
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			apiError(w, http.StatusBadRequest, "invalid_parameter", "page must be a positive number")
+			return nil, nil, false
+		}
+		p.Page = n
+	}
+	if v := query.Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > apiMaxPerPage {
+			apiError(w, http.StatusBadRequest, "invalid_parameter", "per_page must be between 1 and "+strconv.Itoa(apiMaxPerPage))
+			return nil, nil, false
+		}
+		p.PerPage = n
+	}
+
+	p.TotalPages = (p.Total + p.PerPage - 1) / p.PerPage
+	start := (p.Page - 1) * p.PerPage
+	if start >= len(items) {
+		return []T{}, p, true
+	}
+	end := min(start+p.PerPage, len(items))
+	return items[start:end], p, true
+}
@@ -0,0 +1,619 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lexicon/internal/database"
+	"lexicon/internal/diff"
+	"lexicon/internal/markdown"
+	"lexicon/internal/middleware"
+	"lexicon/internal/webhook"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// apiPage is a page as listed by the API.
+type apiPage struct {
+	Slug       string    `json:"slug"`
+	Title      string    `json:"title"`
+	Phantom    bool      `json:"phantom"`
+	Sealed     bool      `json:"sealed,omitempty"`
+	RedirectTo string    `json:"redirect_to,omitempty"`
+	URL        string    `json:"url"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// apiPageDetail is a single page with its current content.
+type apiPageDetail struct {
+	apiPage
+	RedirectedFrom string       `json:"redirected_from,omitempty"`
+	Content        string       `json:"content"`
+	Revision       *apiRevision `json:"revision"`
+}
+
+type apiRevision struct {
+	ID        int64     `json:"id"`
+	Author    string    `json:"author"`
+	Summary   string    `json:"summary"`
+	Minor     bool      `json:"minor"`
+	RevertOf  *int64    `json:"revert_of,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Content   string    `json:"content,omitempty"`
+}
+
+type apiRendered struct {
+	Slug       string `json:"slug"`
+	RevisionID int64  `json:"revision_id"`
+	HTML       string `json:"html"`
+}
+
+type apiPhantom struct {
+	apiPage
+	CitedIn        *apiPageRef `json:"cited_in,omitempty"`
+	ClaimedBy      string      `json:"claimed_by,omitempty"`
+	ClaimExpiresAt *time.Time  `json:"claim_expires_at,omitempty"`
+}
+
+type apiPageRef struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+type apiComment struct {
+	ID        int64     `json:"id"`
+	Author    string    `json:"author"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type apiSearchResult struct {
+	Slug    string `json:"slug"`
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+}
+
+type apiViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// apiPageRequest is the body of a page create or update.
+type apiPageRequest struct {
+	Slug          string `json:"slug"`
+	Title         string `json:"title"`
+	Content       string `json:"content"`
+	Summary       string `json:"summary"`
+	Minor         bool   `json:"minor"`
+	OverrideRules bool   `json:"override_rules"`
+
+	// BaseRevisionID is the revision the edit started from. Edits saved
+	// since are merged in, as with the edit form.
+	BaseRevisionID int64 `json:"base_revision_id"`
+}
+
+func newAPIPage(page *database.Page) apiPage {
+	return apiPage{
+		Slug:       page.Slug,
+		Title:      page.Title,
+		Phantom:    page.IsPhantom,
+		RedirectTo: page.RedirectTo,
+		URL:        "/" + page.Slug,
+		CreatedAt:  page.CreatedAt,
+		UpdatedAt:  page.UpdatedAt,
+	}
+}
+
+func newAPIRevision(rev *database.Revision, withContent bool) *apiRevision {
+	r := &apiRevision{
+		ID:        rev.ID,
+		Author:    rev.AuthorUsername,
+		Summary:   rev.Summary,
+		Minor:     rev.IsMinor,
+		RevertOf:  rev.RevertOfID,
+		CreatedAt: rev.CreatedAt,
+	}
+	if withContent {
+		r.Content = rev.Content
+	}
+	return r
+}
+
+// apiLookupPage finds the page named in the URL, following aliases and
+// former slugs. Deleted pages are not found. It writes an error response
+// and returns nil if there is no such page.
+func (h *Handler) apiLookupPage(w http.ResponseWriter, r *http.Request) (page *database.Page, redirectedFrom string) {
+	slug := chi.URLParam(r, "slug")
+
+	page, err := h.DB.GetPageBySlug(slug)
+	if err == database.ErrNotFound {
+		if page, err = h.DB.ResolveRedirect(slug); err == nil {
+			redirectedFrom = slug
+		}
+	}
+	if err == database.ErrNotFound || (page != nil && page.DeletedAt != nil) {
+		apiError(w, http.StatusNotFound, "not_found", "Page not found")
+		return nil, ""
+	}
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return nil, ""
+	}
+	return page, redirectedFrom
+}
+
+// apiPageResponse describes a page for the current user. Sealed entries
+// they may not read yet look like phantoms.
+func (h *Handler) apiPageResponse(r *http.Request, page *database.Page, redirectedFrom string) (apiResponse, error) {
+	detail := apiPageDetail{apiPage: newAPIPage(page), RedirectedFrom: redirectedFrom}
+	if !h.DB.CanViewSealed(page, middleware.GetUser(r)) {
+		detail.Phantom = true
+		detail.Sealed = true
+		return apiResponse{Data: detail}, nil
+	}
+	detail.Sealed = page.Sealed
+
+	if !page.IsPhantom {
+		rev, err := h.DB.GetCurrentRevision(page.ID)
+		if err != nil {
+			return apiResponse{}, err
+		}
+		detail.Content = rev.Content
+		detail.Revision = newAPIRevision(rev, false)
+	}
+	return apiResponse{Data: detail}, nil
+}
+
+// APIListPages lists written pages alphabetically.
+func (h *Handler) APIListPages(w http.ResponseWriter, r *http.Request) {
+	pages, err := h.DB.ListPages()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+
+	pages, p, ok := paginate(w, r, pages)
+	if !ok {
+		return
+	}
+	data := make([]apiPage, len(pages))
+	for i, page := range pages {
+		data[i] = newAPIPage(page)
+	}
+	apiJSON(w, r, http.StatusOK, apiResponse{Data: data, Pagination: p})
+}
+
+// APIGetPage returns a page with its current content.
+func (h *Handler) APIGetPage(w http.ResponseWriter, r *http.Request) {
+	page, redirectedFrom := h.apiLookupPage(w, r)
+	if page == nil {
+		return
+	}
+
+	resp, err := h.apiPageResponse(r, page, redirectedFrom)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+	apiJSON(w, r, http.StatusOK, resp)
+}
+
+// APICreatePage writes a new page, or a phantom. The slug defaults to one
+// made from the title.
+func (h *Handler) APICreatePage(w http.ResponseWriter, r *http.Request) {
+	var req apiPageRequest
+	if !decodeAPIBody(w, r, &req) {
+		return
+	}
+
+	slug := req.Slug
+	if slug == "" {
+		slug = req.Title
+	}
+	slug = database.Slugify(slug)
+	if slug == "" {
+		apiError(w, http.StatusUnprocessableEntity, "validation_failed", "A title or slug is required")
+		return
+	}
+
+	page, err := h.DB.GetPageBySlug(slug)
+	if err != nil && err != database.ErrNotFound {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+	if page != nil && !page.IsPhantom {
+		apiError(w, http.StatusConflict, "page_exists", "A page already exists at /"+slug+"; update it with PUT")
+		return
+	}
+
+	h.apiSavePage(w, r, slug, page, req)
+}
+
+// APIUpdatePage saves a new revision of a page, or writes it if it doesn't
+// exist yet. Updating a written page needs either If-Match, so the save
+// only happens if the page still has that ETag, or a base_revision_id to
+// merge concurrent edits against; otherwise it could silently overwrite
+// someone else's edit.
+func (h *Handler) APIUpdatePage(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	if database.Slugify(slug) != slug {
+		apiError(w, http.StatusBadRequest, "invalid_slug", "Slugs use only lowercase letters, digits and hyphens; try /"+database.Slugify(slug))
+		return
+	}
+
+	var req apiPageRequest
+	if !decodeAPIBody(w, r, &req) {
+		return
+	}
+
+	page, err := h.DB.GetPageBySlug(slug)
+	if err != nil && err != database.ErrNotFound {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+
+	if match := r.Header.Get("If-Match"); match != "" {
+		if page == nil {
+			apiError(w, http.StatusPreconditionFailed, "precondition_failed", "Page doesn't exist")
+			return
+		}
+		resp, err := h.apiPageResponse(r, page, "")
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+			return
+		}
+		tag, err := apiETag(resp)
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, "internal_error", "Failed to encode response")
+			return
+		}
+		if !etagMatches(match, tag) {
+			apiError(w, http.StatusPreconditionFailed, "precondition_failed", "Page has changed since it was read")
+			return
+		}
+	} else if page != nil && !page.IsPhantom {
+		if req.BaseRevisionID == 0 {
+			apiError(w, http.StatusPreconditionRequired, "precondition_required", "Send If-Match or base_revision_id to update an existing page")
+			return
+		}
+		if !h.apiMergeConcurrentEdit(w, page, &req) {
+			return
+		}
+	}
+
+	h.apiSavePage(w, r, slug, page, req)
+}
+
+// apiMergeConcurrentEdit merges edits saved since req's base revision into
+// its content, like mergeConcurrentEdit does for the edit form. It writes an
+// error response and returns false if they conflict.
+func (h *Handler) apiMergeConcurrentEdit(w http.ResponseWriter, page *database.Page, req *apiPageRequest) bool {
+	current, err := h.DB.GetCurrentRevision(page.ID)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return false
+	}
+	if current.ID == req.BaseRevisionID {
+		return true
+	}
+
+	base, err := h.pageRevision(page, strconv.FormatInt(req.BaseRevisionID, 10))
+	if err == database.ErrNotFound {
+		apiError(w, http.StatusUnprocessableEntity, "validation_failed", "base_revision_id is not a revision of this page")
+		return false
+	}
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return false
+	}
+
+	merged, ok := diff.Merge3(base.Content, req.Content, current.Content)
+	if !ok {
+		apiErrorWithDetails(w, http.StatusConflict, "edit_conflict",
+			"The page was edited by "+current.AuthorUsername+" since your base revision, and the edits conflict",
+			map[string]int64{"current_revision_id": current.ID})
+		return false
+	}
+	req.Content = merged
+	return true
+}
+
+// apiSavePage applies the same checks as the edit form, then creates or
+// updates the page and its phantoms.
+func (h *Handler) apiSavePage(w http.ResponseWriter, r *http.Request, slug string, page *database.Page, req apiPageRequest) {
+	user := middleware.GetUser(r)
+	meta := database.RevisionMeta{Summary: strings.TrimSpace(req.Summary), IsMinor: req.Minor}
+
+	switch {
+	case req.Title == "":
+		apiError(w, http.StatusUnprocessableEntity, "validation_failed", "Title is required")
+		return
+	case len(req.Title) > 500:
+		apiError(w, http.StatusUnprocessableEntity, "validation_failed", "Title is too long (max 500 characters)")
+		return
+	case len(req.Content) > 500*1024:
+		apiError(w, http.StatusUnprocessableEntity, "validation_failed", "Content is too long (max 500KB)")
+		return
+	case len(meta.Summary) > 300:
+		apiError(w, http.StatusUnprocessableEntity, "validation_failed", "Edit summary is too long (max 300 characters)")
+		return
+	}
+
+	if page != nil && page.DeletedAt != nil {
+		apiError(w, http.StatusConflict, "page_deleted", "This page was deleted; an admin can restore it")
+		return
+	}
+	if page != nil && !h.DB.CanViewSealed(page, user) {
+		apiError(w, http.StatusForbidden, "sealed", sealedMessage)
+		return
+	}
+
+	links := h.Markdown.ExtractLinks(req.Content)
+	if !(user.IsAdmin() && req.OverrideRules) {
+		violations, err := h.ruleViolations(user, page, links)
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+			return
+		}
+		if len(violations) > 0 {
+			details := make([]apiViolation, len(violations))
+			for i, v := range violations {
+				details[i] = apiViolation{Rule: v.Rule, Message: v.Message}
+			}
+			apiErrorWithDetails(w, http.StatusUnprocessableEntity, "rule_violation", "The entry breaks the citation rules", details)
+			return
+		}
+	}
+
+	status := http.StatusOK
+	var err error
+	if page == nil || page.IsPhantom {
+		// A new entry is never a minor edit
+		meta.IsMinor = false
+		status = http.StatusCreated
+		page, err = h.DB.CreatePage(slug, req.Title, req.Content, user.ID, markdown.DatabaseLinks(links), meta)
+		if err == database.ErrClaimed {
+			apiError(w, http.StatusConflict, "claimed", "Someone else has claimed this entry")
+			return
+		}
+	} else {
+		err = h.DB.UpdatePage(page.ID, req.Title, req.Content, user.ID, markdown.DatabaseLinks(links), meta)
+	}
+	if err != nil {
+		log.Printf("API: failed to save %s: %v", slug, err)
+		apiError(w, http.StatusInternalServerError, "internal_error", "Failed to save page")
+		return
+	}
+
+	// Create phantoms for cited pages that don't exist yet
+	phantoms := h.processWikiLinks(links, user.ID, page.ID)
+
+	event := webhook.PageUpdated
+	if status == http.StatusCreated {
+		event = webhook.PageCreated
+	}
+	h.emitPageSaved(r, event, page.ID, user)
+	h.emitPhantoms(r, phantoms, page.ID, user)
+
+	page, err = h.DB.GetPageBySlug(slug)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+	resp, err := h.apiPageResponse(r, page, "")
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+	apiJSON(w, r, status, resp)
+}
+
+// APIPageHTML returns a page's current revision rendered as HTML, with
+// links styled as they would be for the current user.
+func (h *Handler) APIPageHTML(w http.ResponseWriter, r *http.Request) {
+	page, _ := h.apiLookupPage(w, r)
+	if page == nil {
+		return
+	}
+	if page.IsPhantom || !h.DB.CanViewSealed(page, middleware.GetUser(r)) {
+		apiError(w, http.StatusNotFound, "not_written", "This entry hasn't been written yet")
+		return
+	}
+
+	rev, err := h.DB.GetCurrentRevision(page.ID)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+	html, err := h.renderFor(r, rev.Content)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Markdown error")
+		return
+	}
+	apiJSON(w, r, http.StatusOK, apiResponse{Data: apiRendered{Slug: page.Slug, RevisionID: rev.ID, HTML: html}})
+}
+
+// APIListRevisions lists a page's revisions, newest first, without their
+// content.
+func (h *Handler) APIListRevisions(w http.ResponseWriter, r *http.Request) {
+	page, _ := h.apiLookupPage(w, r)
+	if page == nil {
+		return
+	}
+	if !h.DB.CanViewSealed(page, middleware.GetUser(r)) {
+		apiError(w, http.StatusNotFound, "not_written", "This entry hasn't been written yet")
+		return
+	}
+
+	revisions, err := h.DB.ListRevisions(page.ID)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+	revisions, p, ok := paginate(w, r, revisions)
+	if !ok {
+		return
+	}
+	data := make([]*apiRevision, len(revisions))
+	for i, rev := range revisions {
+		data[i] = newAPIRevision(rev, false)
+	}
+	apiJSON(w, r, http.StatusOK, apiResponse{Data: data, Pagination: p})
+}
+
+// APIGetRevision returns one revision of a page with its content.
+func (h *Handler) APIGetRevision(w http.ResponseWriter, r *http.Request) {
+	page, _ := h.apiLookupPage(w, r)
+	if page == nil {
+		return
+	}
+	if !h.DB.CanViewSealed(page, middleware.GetUser(r)) {
+		apiError(w, http.StatusNotFound, "not_written", "This entry hasn't been written yet")
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "revisionID"), 10, 64)
+	if err != nil {
+		apiError(w, http.StatusNotFound, "not_found", "Revision not found")
+		return
+	}
+	rev, err := h.DB.GetRevisionByID(id)
+	if err == database.ErrNotFound || (rev != nil && rev.PageID != page.ID) {
+		apiError(w, http.StatusNotFound, "not_found", "Revision not found")
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+	apiJSON(w, r, http.StatusOK, apiResponse{Data: newAPIRevision(rev, true)})
+}
+
+// APIListPhantoms lists phantoms alphabetically, with where they were first
+// cited and who has claimed them.
+func (h *Handler) APIListPhantoms(w http.ResponseWriter, r *http.Request) {
+	phantoms, err := h.DB.ListPhantomsWithSource()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+
+	phantoms, p, ok := paginate(w, r, phantoms)
+	if !ok {
+		return
+	}
+	data := make([]apiPhantom, len(phantoms))
+	for i, phantom := range phantoms {
+		data[i] = apiPhantom{
+			apiPage:        newAPIPage(phantom.Page),
+			ClaimedBy:      phantom.ClaimedBy,
+			ClaimExpiresAt: phantom.ClaimExpiresAt,
+		}
+		if phantom.SourceSlug != "" {
+			data[i].CitedIn = &apiPageRef{Slug: phantom.SourceSlug, Title: phantom.SourceTitle}
+		}
+	}
+	apiJSON(w, r, http.StatusOK, apiResponse{Data: data, Pagination: p})
+}
+
+// APIListComments lists a page's comments, oldest first.
+func (h *Handler) APIListComments(w http.ResponseWriter, r *http.Request) {
+	page, _ := h.apiLookupPage(w, r)
+	if page == nil {
+		return
+	}
+	if !h.DB.CanViewSealed(page, middleware.GetUser(r)) {
+		apiError(w, http.StatusNotFound, "not_written", "This entry hasn't been written yet")
+		return
+	}
+
+	comments, err := h.DB.ListComments(page.ID)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Database error")
+		return
+	}
+	comments, p, ok := paginate(w, r, comments)
+	if !ok {
+		return
+	}
+	data := make([]apiComment, len(comments))
+	for i, c := range comments {
+		data[i] = newAPIComment(c)
+	}
+	apiJSON(w, r, http.StatusOK, apiResponse{Data: data, Pagination: p})
+}
+
+// APIAddComment adds a comment to a page.
+func (h *Handler) APIAddComment(w http.ResponseWriter, r *http.Request) {
+	page, _ := h.apiLookupPage(w, r)
+	if page == nil {
+		return
+	}
+	user := middleware.GetUser(r)
+	if !h.DB.CanViewSealed(page, user) {
+		apiError(w, http.StatusNotFound, "not_written", "This entry hasn't been written yet")
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if !decodeAPIBody(w, r, &req) {
+		return
+	}
+	if req.Content == "" {
+		apiError(w, http.StatusUnprocessableEntity, "validation_failed", "Comment cannot be empty")
+		return
+	}
+	if len(req.Content) > 10*1024 {
+		apiError(w, http.StatusUnprocessableEntity, "validation_failed", "Comment is too long (max 10KB)")
+		return
+	}
+
+	comment, err := h.DB.CreateComment(page.ID, user.ID, req.Content)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Failed to add comment")
+		return
+	}
+	h.emitComment(r, page, comment, user)
+	apiJSON(w, r, http.StatusCreated, apiResponse{Data: newAPIComment(comment)})
+}
+
+func newAPIComment(c *database.Comment) apiComment {
+	return apiComment{
+		ID:        c.ID,
+		Author:    c.AuthorUsername,
+		Content:   c.Content,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+// APISearch runs a full-text search. Snippets mark matches with <mark>.
+func (h *Handler) APISearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		apiError(w, http.StatusBadRequest, "invalid_parameter", "q is required")
+		return
+	}
+
+	results, err := h.DB.Search(query, 500)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "internal_error", "Search failed")
+		return
+	}
+	results, p, ok := paginate(w, r, results)
+	if !ok {
+		return
+	}
+	data := make([]apiSearchResult, len(results))
+	for i, result := range results {
+		data[i] = apiSearchResult{Slug: result.Slug, Title: result.Title, Snippet: result.Snippet}
+	}
+	apiJSON(w, r, http.StatusOK, apiResponse{Data: data, Pagination: p})
+}
@@ -7,6 +7,7 @@ import (
 
 	"lexicon/internal/database"
 	"lexicon/internal/middleware"
+	"lexicon/internal/webhook"
 )
 
 var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]{3,50}$`)
@@ -167,6 +168,7 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/register", http.StatusSeeOther)
 		return
 	}
+	h.emit(webhook.UserRegistered, user.Username+" joined the wiki", webhook.UserData{User: webhook.User{Username: user.Username}})
 
 	// Create session
 	session, err := h.DB.CreateSession(user.ID)
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+
+	"lexicon/internal/backup"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminBackups lists the database backups.
+func (h *Handler) AdminBackups(w http.ResponseWriter, r *http.Request) {
+	files, err := backup.List(h.Config.BackupDir())
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Failed to list backups")
+		return
+	}
+	interval, _ := h.DB.BackupInterval()
+	keep, _ := h.DB.BackupKeep()
+
+	h.Render(w, r, "admin/backups.html", "Backups", map[string]any{
+		"Backups":  files,
+		"Dir":      h.Config.BackupDir(),
+		"Interval": int(interval.Hours()),
+		"Keep":     keep,
+	})
+}
+
+// AdminCreateBackup takes a backup now.
+func (h *Handler) AdminCreateBackup(w http.ResponseWriter, r *http.Request) {
+	keep, err := h.DB.BackupKeep()
+	if err == nil {
+		var f *backup.File
+		f, err = backup.Create(h.DB, h.Config.BackupDir(), keep)
+		if err == nil {
+			h.AddFlash(r, "success", "Created backup "+f.Name)
+		}
+	}
+	if err != nil {
+		h.AddFlash(r, "danger", "Backup failed: "+err.Error())
+	}
+	http.Redirect(w, r, "/admin/backups", http.StatusSeeOther)
+}
+
+// AdminDownloadBackup sends a backup file.
+func (h *Handler) AdminDownloadBackup(w http.ResponseWriter, r *http.Request) {
+	f, err := backup.Get(h.Config.BackupDir(), chi.URLParam(r, "name"))
+	if err != nil {
+		h.NotFound(w, r)
+		return
+	}
+
+	file, err := os.Open(f.Path)
+	if err != nil {
+		h.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+f.Name+`"`)
+	http.ServeContent(w, r, f.Name, f.CreatedAt, file)
+}
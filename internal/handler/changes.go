@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"lexicon/internal/database"
+)
+
+const changesPerPage = 50
+
+// RecentChanges shows the stream of edits and comments across the wiki,
+// filterable by user, page and date range.
+func (h *Handler) RecentChanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := database.ChangeFilter{
+		Username:  strings.TrimSpace(query.Get("user")),
+		PageSlug:  database.Slugify(strings.TrimSpace(query.Get("page"))),
+		HideMinor: query.Get("hide_minor") == "1",
+		Limit:     changesPerPage + 1,
+	}
+	if since, err := time.ParseInLocation("2006-01-02", query.Get("from"), time.Local); err == nil {
+		filter.Since = since
+	}
+	if until, err := time.ParseInLocation("2006-01-02", query.Get("to"), time.Local); err == nil {
+		// The end date is inclusive
+		filter.Until = until.AddDate(0, 0, 1)
+	}
+
+	pageNum, _ := strconv.Atoi(query.Get("p"))
+	if pageNum < 1 {
+		pageNum = 1
+	}
+	filter.Offset = (pageNum - 1) * changesPerPage
+
+	changes, err := h.DB.ListChanges(filter)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	hasNext := len(changes) > changesPerPage
+	if hasNext {
+		changes = changes[:changesPerPage]
+	}
+
+	users, _ := h.DB.ListUsers()
+
+	h.Render(w, r, "special/recent-changes.html", "Recent Changes", map[string]any{
+		"Changes":    changes,
+		"Users":      users,
+		"FilterUser": filter.Username,
+		"FilterPage": query.Get("page"),
+		"From":       query.Get("from"),
+		"To":         query.Get("to"),
+		"HideMinor":  filter.HideMinor,
+		"PageNum":    pageNum,
+		"PrevURL":    changesPageURL(query, pageNum-1),
+		"NextURL":    changesPageURL(query, pageNum+1),
+		"HasPrev":    pageNum > 1,
+		"HasNext":    hasNext,
+	})
+}
+
+// changesPageURL returns the recent-changes URL for page n with the current
+// filters preserved.
+func changesPageURL(query url.Values, n int) string {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	if n > 1 {
+		q.Set("p", strconv.Itoa(n))
+	} else {
+		q.Del("p")
+	}
+	if len(q) == 0 {
+		return "/special/recent-changes"
+	}
+	return "/special/recent-changes?" + q.Encode()
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+
+	"lexicon/internal/database"
+	"lexicon/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ClaimPage reserves a phantom for the current user.
+func (h *Handler) ClaimPage(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.claimablePage(w, r)
+	if !ok {
+		return
+	}
+	user := middleware.GetUser(r)
+
+	duration, err := h.DB.ClaimDuration()
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	claim, err := h.DB.ClaimPage(page.ID, user.ID, duration)
+	switch {
+	case err == database.ErrClaimed:
+		h.AddFlash(r, "danger", "Someone else has already claimed this entry")
+	case err != nil:
+		h.AddFlash(r, "danger", "Failed to claim entry")
+	default:
+		h.AddFlash(r, "success", "You have claimed "+page.Title+" until "+claim.ExpiresAt.Format("Jan 2, 2006 3:04 PM"))
+	}
+	http.Redirect(w, r, "/"+page.Slug, http.StatusSeeOther)
+}
+
+// ReleaseClaim gives up a claim. Admins may release anyone's claim.
+func (h *Handler) ReleaseClaim(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.claimablePage(w, r)
+	if !ok {
+		return
+	}
+	user := middleware.GetUser(r)
+
+	claim, err := h.DB.GetClaim(page.ID)
+	if err == database.ErrNotFound {
+		http.Redirect(w, r, "/"+page.Slug, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if claim.UserID != user.ID && !user.IsAdmin() {
+		h.RenderError(w, r, http.StatusForbidden, "You can only release your own claims")
+		return
+	}
+
+	if err := h.DB.ReleaseClaim(page.ID); err != nil {
+		h.AddFlash(r, "danger", "Failed to release claim")
+	} else {
+		h.AddFlash(r, "success", "Claim released")
+	}
+	http.Redirect(w, r, "/"+page.Slug, http.StatusSeeOther)
+}
+
+// claimablePage loads the phantom named in the URL. Only phantoms can be
+// claimed.
+func (h *Handler) claimablePage(w http.ResponseWriter, r *http.Request) (*database.Page, bool) {
+	page, err := h.DB.GetPageBySlug(chi.URLParam(r, "slug"))
+	if err == database.ErrNotFound {
+		h.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	if !page.IsPhantom {
+		h.AddFlash(r, "warning", "Only unwritten entries can be claimed")
+		http.Redirect(w, r, "/"+page.Slug, http.StatusSeeOther)
+		return nil, false
+	}
+	return page, true
+}
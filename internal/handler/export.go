@@ -1,123 +1,79 @@
 package handler
 
 import (
-	"archive/zip"
-	"encoding/json"
-	"fmt"
+	"log"
 	"net/http"
-	"time"
+
+	"lexicon/internal/archive"
+	"lexicon/internal/book"
+	"lexicon/internal/site"
 )
 
 // Export generates a ZIP file with all pages as markdown.
 func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
-	wikiTitle, _ := h.DB.WikiTitle()
-
 	// Set headers for ZIP download
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", `attachment; filename="lexicon-export.zip"`)
 
-	// Create ZIP writer
-	zw := zip.NewWriter(w)
-	defer zw.Close()
-
-	// Get all non-phantom pages
-	pages, err := h.DB.ListPages()
-	if err != nil {
-		http.Error(w, "Export failed", http.StatusInternalServerError)
-		return
+	if err := archive.Export(h.DB, w); err != nil {
+		log.Printf("Export failed: %v", err)
 	}
+}
 
-	// Get all phantoms
-	phantoms, err := h.DB.ListPhantoms()
-	if err != nil {
-		http.Error(w, "Export failed", http.StatusInternalServerError)
-		return
-	}
+// ExportHistory streams every revision, comment, redirect, user, setting and
+// deleted page as JSON Lines.
+func (h *Handler) ExportHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="lexicon-history.jsonl"`)
 
-	// Export each page
-	for _, page := range pages {
-		rev, err := h.DB.GetCurrentRevision(page.ID)
-		if err != nil {
-			continue
-		}
+	if err := archive.ExportHistory(r.Context(), h.DB, w); err != nil {
+		log.Printf("History export failed: %v", err)
+	}
+}
 
-		revCount, _ := h.DB.RevisionCount(page.ID)
+// ExportSite generates the wiki as a static website and sends it as a ZIP.
+func (h *Handler) ExportSite(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="lexicon-site.zip"`)
 
-		content := fmt.Sprintf(`---
-title: %s
-slug: %s
-created: %s
-updated: %s
-author: %s
-revisions: %d
----
+	gen := &site.Generator{DB: h.DB, Templates: h.TemplateFS, Static: h.StaticFS}
+	if err := gen.WriteZip(w); err != nil {
+		log.Printf("Site export failed: %v", err)
+	}
+}
 
-%s
-`,
-			page.Title,
-			page.Slug,
-			page.CreatedAt.Format(time.RFC3339),
-			page.UpdatedAt.Format(time.RFC3339),
-			rev.AuthorUsername,
-			revCount,
-			rev.Content,
-		)
+// AdminBook shows the options for exporting the wiki as a book.
+func (h *Handler) AdminBook(w http.ResponseWriter, r *http.Request) {
+	h.Render(w, r, "admin/book.html", "Export Book", map[string]any{})
+}
 
-		f, err := zw.Create("pages/" + page.Slug + ".md")
-		if err != nil {
-			continue
-		}
-		f.Write([]byte(content))
+// ExportBook sends the wiki as an EPUB or a single HTML document.
+func (h *Handler) ExportBook(w http.ResponseWriter, r *http.Request) {
+	order, err := book.ParseOrder(r.FormValue("order"))
+	format := r.FormValue("format")
+	if err != nil || (format != "epub" && format != "html") {
+		h.AddFlash(r, "danger", "Choose a format and an order")
+		http.Redirect(w, r, "/admin/book", http.StatusSeeOther)
+		return
 	}
 
-	// Create metadata.json
-	metadata := struct {
-		ExportedAt    string `json:"exported_at"`
-		WikiTitle     string `json:"wiki_title"`
-		TotalPages    int    `json:"total_pages"`
-		TotalPhantoms int    `json:"total_phantoms"`
-		Phantoms      []struct {
-			Slug          string `json:"slug"`
-			Title         string `json:"title"`
-			FirstCitedBy  string `json:"first_cited_by"`
-			FirstCitedIn  string `json:"first_cited_in"`
-		} `json:"phantoms"`
-	}{
-		ExportedAt:    time.Now().Format(time.RFC3339),
-		WikiTitle:     wikiTitle,
-		TotalPages:    len(pages),
-		TotalPhantoms: len(phantoms),
+	b, err := book.Build(h.DB, order)
+	if err != nil {
+		log.Printf("Book export failed: %v", err)
+		h.RenderError(w, r, http.StatusInternalServerError, "Failed to build book")
+		return
 	}
 
-	for _, phantom := range phantoms {
-		var citedBy, citedIn string
-		if phantom.FirstCitedByUserID != nil {
-			if user, err := h.DB.GetUserByID(*phantom.FirstCitedByUserID); err == nil {
-				citedBy = user.Username
-			}
-		}
-		if phantom.FirstCitedInPageID != nil {
-			if page, err := h.DB.GetPageByID(*phantom.FirstCitedInPageID); err == nil {
-				citedIn = page.Slug
-			}
-		}
-
-		metadata.Phantoms = append(metadata.Phantoms, struct {
-			Slug          string `json:"slug"`
-			Title         string `json:"title"`
-			FirstCitedBy  string `json:"first_cited_by"`
-			FirstCitedIn  string `json:"first_cited_in"`
-		}{
-			Slug:         phantom.Slug,
-			Title:        phantom.Title,
-			FirstCitedBy: citedBy,
-			FirstCitedIn: citedIn,
-		})
+	if format == "epub" {
+		w.Header().Set("Content-Type", "application/epub+zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="lexicon.epub"`)
+		err = b.WriteEPUB(w, h.TemplateFS)
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="lexicon.html"`)
+		err = b.WriteHTML(w, h.TemplateFS)
 	}
-
-	metaJSON, _ := json.MarshalIndent(metadata, "", "  ")
-	f, err := zw.Create("metadata.json")
-	if err == nil {
-		f.Write(metaJSON)
+	if err != nil {
+		log.Printf("Book export failed: %v", err)
 	}
 }
@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+
+	"lexicon/internal/database"
+	"lexicon/internal/feed"
+	"lexicon/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const feedSize = 50
+
+// ChangesFeed serves recent revisions across the wiki.
+func (h *Handler) ChangesFeed(w http.ResponseWriter, r *http.Request) {
+	edits, err := h.DB.ListRecentEdits(feedSize, true)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	base := baseURL(r)
+	f := &feed.Feed{
+		Title: h.feedTitle("Recent changes"),
+		Link:  base + "/special/recent-changes",
+	}
+	for _, edit := range edits {
+		title := edit.PageTitle
+		if edit.Summary != "" {
+			title += ": " + edit.Summary
+		}
+		content := fmt.Sprintf("<p>Edited by %s", html.EscapeString(edit.AuthorUsername))
+		if edit.IsMinor {
+			content += " (minor edit)"
+		}
+		content += fmt.Sprintf(`.</p><p><a href="%s">View changes</a></p>`,
+			html.EscapeString(fmt.Sprintf("%s/%s/diff?to=%d", base, edit.PageSlug, edit.ID)))
+
+		f.Entries = append(f.Entries, feed.Entry{
+			ID:      fmt.Sprintf("%s/%s/revision/%d", base, edit.PageSlug, edit.ID),
+			Title:   title,
+			Link:    fmt.Sprintf("%s/%s/diff?to=%d", base, edit.PageSlug, edit.ID),
+			Author:  edit.AuthorUsername,
+			Updated: edit.CreatedAt,
+			Summary: edit.Summary,
+			Content: content,
+		})
+	}
+
+	h.serveFeed(w, r, f)
+}
+
+// NewPagesFeed serves newly written entries with their first revision.
+func (h *Handler) NewPagesFeed(w http.ResponseWriter, r *http.Request) {
+	edits, err := h.DB.ListNewPages(feedSize)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	base := baseURL(r)
+	f := &feed.Feed{
+		Title: h.feedTitle("New entries"),
+		Link:  base + "/pages",
+	}
+	for _, edit := range edits {
+		content, err := h.Markdown.Render(edit.Content)
+		if err != nil {
+			content = "<pre>" + html.EscapeString(edit.Content) + "</pre>"
+		}
+		f.Entries = append(f.Entries, feed.Entry{
+			ID:      fmt.Sprintf("%s/%s/revision/%d", base, edit.PageSlug, edit.ID),
+			Title:   edit.PageTitle,
+			Link:    base + "/" + edit.PageSlug,
+			Author:  edit.AuthorUsername,
+			Updated: edit.CreatedAt,
+			Content: content,
+		})
+	}
+
+	h.serveFeed(w, r, f)
+}
+
+// PhantomsFeed serves newly cited phantoms.
+func (h *Handler) PhantomsFeed(w http.ResponseWriter, r *http.Request) {
+	phantoms, err := h.DB.ListRecentPhantoms(feedSize)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	base := baseURL(r)
+	f := &feed.Feed{
+		Title: h.feedTitle("New phantoms"),
+		Link:  base + "/pages/phantoms",
+	}
+	usernames := make(map[int64]string)
+	for _, phantom := range phantoms {
+		entry := feed.Entry{
+			ID:      fmt.Sprintf("%s/%s#phantom-%d", base, phantom.Slug, phantom.ID),
+			Title:   phantom.Title,
+			Link:    base + "/" + phantom.Slug,
+			Updated: phantom.CreatedAt,
+		}
+		if phantom.FirstCitedByUserID != nil {
+			id := *phantom.FirstCitedByUserID
+			if _, ok := usernames[id]; !ok {
+				if user, err := h.DB.GetUserByID(id); err == nil {
+					usernames[id] = user.Username
+				}
+			}
+			entry.Author = usernames[id]
+		}
+		if phantom.SourceTitle != "" {
+			entry.Summary = "First cited in " + phantom.SourceTitle
+		}
+		f.Entries = append(f.Entries, entry)
+	}
+
+	h.serveFeed(w, r, f)
+}
+
+// CommentsFeed serves the comment thread of a single page.
+func (h *Handler) CommentsFeed(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+
+	page, err := h.DB.GetPageBySlug(slug)
+	if err == database.ErrNotFound || (page != nil && (page.IsPhantom || page.DeletedAt != nil || !h.DB.CanViewSealed(page, middleware.GetUser(r)))) {
+		h.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	comments, err := h.DB.ListComments(page.ID)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	base := baseURL(r)
+	f := &feed.Feed{
+		Title: h.feedTitle("Comments on " + page.Title),
+		Link:  base + "/" + page.Slug + "#comments",
+	}
+	// Newest first, like the other feeds
+	for i := len(comments) - 1; i >= 0 && len(f.Entries) < feedSize; i-- {
+		comment := comments[i]
+		f.Entries = append(f.Entries, feed.Entry{
+			ID:      fmt.Sprintf("%s/%s#comment-%d", base, page.Slug, comment.ID),
+			Title:   "Comment by " + comment.AuthorUsername,
+			Link:    base + "/" + page.Slug + "#comments",
+			Author:  comment.AuthorUsername,
+			Updated: comment.CreatedAt,
+			Summary: comment.Content,
+		})
+	}
+
+	h.serveFeed(w, r, f)
+}
+
+// AccountFeeds shows the user's feed token settings.
+func (h *Handler) AccountFeeds(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	hasToken, err := h.DB.HasFeedToken(user.ID)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.renderFeeds(w, r, "", hasToken)
+}
+
+// ResetFeedToken issues a new feed token, invalidating old feed URLs, and
+// shows the new URLs once.
+func (h *Handler) ResetFeedToken(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	token, err := h.DB.ResetFeedToken(user.ID)
+	if err != nil {
+		h.AddFlash(r, "danger", "Failed to reset feed token")
+		http.Redirect(w, r, "/account/feeds", http.StatusSeeOther)
+		return
+	}
+	h.renderFeeds(w, r, token, true)
+}
+
+// renderFeeds shows the feed token settings, with the private feed URLs if
+// a token was just issued.
+func (h *Handler) renderFeeds(w http.ResponseWriter, r *http.Request, token string, hasToken bool) {
+	data := map[string]any{"HasToken": hasToken}
+	if token != "" {
+		query := "?token=" + url.QueryEscape(token)
+		base := baseURL(r)
+		data["Feeds"] = []struct{ Name, Atom, RSS string }{
+			{"Recent changes", base + "/feeds/changes.atom" + query, base + "/feeds/changes.rss" + query},
+			{"New entries", base + "/feeds/pages.atom" + query, base + "/feeds/pages.rss" + query},
+			{"New phantoms", base + "/feeds/phantoms.atom" + query, base + "/feeds/phantoms.rss" + query},
+		}
+		data["CommentsPattern"] = base + "/{page}/comments.atom" + query
+	}
+
+	h.Render(w, r, "account/feeds.html", "Feeds", data)
+}
+
+// serveFeed writes f in the format named by the route's format parameter.
+func (h *Handler) serveFeed(w http.ResponseWriter, r *http.Request, f *feed.Feed) {
+	f.Self = baseURL(r) + r.URL.RequestURI()
+
+	var buf bytes.Buffer
+	var err error
+	if chi.URLParam(r, "format") == "rss" {
+		w.Header().Set("Content-Type", feed.RSSContentType)
+		err = f.WriteRSS(&buf)
+	} else {
+		w.Header().Set("Content-Type", feed.AtomContentType)
+		err = f.WriteAtom(&buf)
+	}
+	if err != nil {
+		log.Printf("Feed error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf.Bytes())
+}
+
+func (h *Handler) feedTitle(name string) string {
+	wikiTitle, _ := h.DB.WikiTitle()
+	if wikiTitle == "" {
+		wikiTitle = "Lexicon Wiki"
+	}
+	return wikiTitle + ": " + name
+}
+
+// baseURL returns the scheme and host the request was made to.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lexicon/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// deadlineLayout matches the value of an <input type="datetime-local">.
+const deadlineLayout = "2006-01-02T15:04"
+
+// AdminGame renders the game management page.
+func (h *Handler) AdminGame(w http.ResponseWriter, r *http.Request) {
+	rounds, err := h.DB.ListRounds()
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	current, _ := h.DB.CurrentRound()
+
+	h.Render(w, r, "admin/game.html", "Game", map[string]any{
+		"Rounds":  rounds,
+		"Current": current,
+	})
+}
+
+// AdminCreateRound adds a round to the end of the game.
+func (h *Handler) AdminCreateRound(w http.ResponseWriter, r *http.Request) {
+	label := strings.TrimSpace(r.FormValue("label"))
+	if label == "" {
+		h.AddFlash(r, "danger", "Round label is required")
+		http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+		return
+	}
+
+	deadline, ok := parseDeadline(r.FormValue("deadline"))
+	if !ok {
+		h.AddFlash(r, "danger", "Invalid deadline")
+		http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+		return
+	}
+
+	if _, err := h.DB.CreateRound(label, deadline); err != nil {
+		h.AddFlash(r, "danger", "Failed to create round")
+	} else {
+		h.AddFlash(r, "success", "Round "+label+" created")
+	}
+	http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+}
+
+// AdminCreateAlphabetRounds adds one round per letter, A to Z.
+func (h *Handler) AdminCreateAlphabetRounds(w http.ResponseWriter, r *http.Request) {
+	for letter := 'A'; letter <= 'Z'; letter++ {
+		if _, err := h.DB.CreateRound(string(letter), nil); err != nil {
+			h.AddFlash(r, "danger", "Failed to create rounds")
+			http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+			return
+		}
+	}
+
+	h.AddFlash(r, "success", "Rounds A to Z created")
+	http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+}
+
+// AdminRound shows a round with the entries and revisions written in it.
+func (h *Handler) AdminRound(w http.ResponseWriter, r *http.Request) {
+	round, ok := h.roundFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	pages, _ := h.DB.ListRoundPages(round.ID)
+	revisions, _ := h.DB.ListRoundRevisions(round.ID)
+
+	h.Render(w, r, "admin/round.html", "Round "+round.Label, map[string]any{
+		"Round":     round,
+		"Pages":     pages,
+		"Revisions": revisions,
+	})
+}
+
+// AdminUpdateRound changes a round's label and deadline.
+func (h *Handler) AdminUpdateRound(w http.ResponseWriter, r *http.Request) {
+	round, ok := h.roundFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	label := strings.TrimSpace(r.FormValue("label"))
+	deadline, ok := parseDeadline(r.FormValue("deadline"))
+	if label == "" || !ok {
+		h.AddFlash(r, "danger", "Invalid label or deadline")
+		http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+		return
+	}
+
+	if err := h.DB.UpdateRound(round.ID, label, deadline); err != nil {
+		h.AddFlash(r, "danger", "Failed to update round")
+	} else {
+		h.AddFlash(r, "success", "Round "+label+" updated")
+	}
+	http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+}
+
+// AdminOpenRound makes a round the current one. Entries of the round it
+// closes are announced as they're published.
+func (h *Handler) AdminOpenRound(w http.ResponseWriter, r *http.Request) {
+	round, ok := h.roundFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if published, err := h.DB.OpenRound(round.ID); err != nil {
+		h.AddFlash(r, "danger", "Failed to open round")
+	} else {
+		h.emitPublished(r, published)
+		h.AddFlash(r, "success", "Round "+round.Label+" is now open")
+	}
+	http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+}
+
+// AdminCloseRound ends the open round and announces its published entries.
+func (h *Handler) AdminCloseRound(w http.ResponseWriter, r *http.Request) {
+	round, ok := h.roundFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if published, err := h.DB.CloseRound(round.ID); err != nil {
+		h.AddFlash(r, "danger", "Failed to close round")
+	} else {
+		h.emitPublished(r, published)
+		h.AddFlash(r, "success", "Round "+round.Label+" closed")
+	}
+	http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+}
+
+// AdminPublishRound reveals a round's sealed entries ahead of its deadline.
+func (h *Handler) AdminPublishRound(w http.ResponseWriter, r *http.Request) {
+	round, ok := h.roundFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	published, err := h.DB.PublishRound(round.ID)
+	if err != nil {
+		h.AddFlash(r, "danger", "Failed to publish round")
+	} else {
+		h.emitPublished(r, published)
+		n := len(published)
+		h.AddFlash(r, "success", fmt.Sprintf("Published %d %s from round %s", n, pluralize(n, "entry", "entries"), round.Label))
+	}
+	http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+}
+
+// AdminDeleteRound removes a round nothing was written in.
+func (h *Handler) AdminDeleteRound(w http.ResponseWriter, r *http.Request) {
+	round, ok := h.roundFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.DB.DeleteRound(round.ID)
+	switch {
+	case err == database.ErrRoundInUse:
+		h.AddFlash(r, "danger", "Entries were written in this round, so it can't be deleted")
+	case err != nil:
+		h.AddFlash(r, "danger", "Failed to delete round")
+	default:
+		h.AddFlash(r, "success", "Round "+round.Label+" deleted")
+	}
+	http.Redirect(w, r, "/admin/game", http.StatusSeeOther)
+}
+
+func (h *Handler) roundFromURL(w http.ResponseWriter, r *http.Request) (*database.Round, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "roundID"), 10, 64)
+	if err != nil {
+		h.NotFound(w, r)
+		return nil, false
+	}
+
+	round, err := h.DB.GetRound(id)
+	if err == database.ErrNotFound {
+		h.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	return round, true
+}
+
+// parseDeadline parses an optional datetime-local value in server time.
+func parseDeadline(value string) (*time.Time, bool) {
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.ParseInLocation(deadlineLayout, value, time.Local)
+	if err != nil {
+		return nil, false
+	}
+	return &t, true
+}
+
+// timeUntil describes the time left until t in days, hours or minutes.
+func timeUntil(t time.Time) string {
+	d := time.Until(t)
+	switch {
+	case d <= 0:
+		return "deadline passed"
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days left", int(d.Hours()/24))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours left", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d minutes left", int(d.Minutes())+1)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"lexicon/internal/database"
 	"lexicon/internal/markdown"
 	"lexicon/internal/middleware"
+	"lexicon/internal/scheduler"
 )
 
 // Flash represents a flash message.
@@ -27,6 +28,12 @@ type Handler struct {
 	templates map[string]*template.Template
 	Markdown  *markdown.Renderer
 	CSRFStore *middleware.CSRFStore
+	Scheduler *scheduler.Scheduler
+
+	// TemplateFS and StaticFS hold the (possibly customized) templates and
+	// assets, for exports that reuse the wiki's look
+	TemplateFS fs.FS
+	StaticFS   fs.FS
 
 	flashMu sync.RWMutex
 	flashes map[string][]Flash // sessionID -> flashes
@@ -35,25 +42,16 @@ type Handler struct {
 // New creates a new Handler.
 func New(cfg *config.Config, db *database.DB, tmplFS fs.FS) (*Handler, error) {
 	h := &Handler{
-		DB:        db,
-		Config:    cfg,
-		CSRFStore: middleware.NewCSRFStore(),
-		flashes:   make(map[string][]Flash),
-		templates: make(map[string]*template.Template),
+		DB:         db,
+		Config:     cfg,
+		CSRFStore:  middleware.NewCSRFStore(),
+		TemplateFS: tmplFS,
+		flashes:    make(map[string][]Flash),
+		templates:  make(map[string]*template.Template),
 	}
 
 	// Create markdown renderer with page checker
-	h.Markdown = markdown.New(func(slug string) (bool, bool) {
-		exists, err := db.PageExists(slug)
-		if err != nil || !exists {
-			return false, false
-		}
-		isPhantom, err := db.IsPhantom(slug)
-		if err != nil {
-			return true, false
-		}
-		return true, isPhantom
-	})
+	h.Markdown = markdown.New(h.pageStatus)
 
 	// Template functions
 	funcMap := template.FuncMap{
@@ -73,6 +71,10 @@ func New(cfg *config.Config, db *database.DB, tmplFS fs.FS) (*Handler, error) {
 		if err != nil {
 			return err
 		}
+		// The static site and book exports load their own templates
+		if d.IsDir() && (path == "site" || path == "book") {
+			return fs.SkipDir
+		}
 		if d.IsDir() || !strings.HasSuffix(path, ".html") || path == "layout.html" {
 			return nil
 		}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"lexicon/internal/archive"
+	"lexicon/internal/middleware"
+)
+
+// AdminImportForm shows the form for importing an export ZIP.
+func (h *Handler) AdminImportForm(w http.ResponseWriter, r *http.Request) {
+	h.renderImport(w, r, nil)
+}
+
+// AdminImport imports an uploaded export ZIP and shows the report.
+func (h *Handler) AdminImport(w http.ResponseWriter, r *http.Request) {
+	policy, err := archive.ParsePolicy(r.FormValue("policy"))
+	if err != nil {
+		h.AddFlash(r, "danger", "Choose what to do with existing pages")
+		http.Redirect(w, r, "/admin/import", http.StatusSeeOther)
+		return
+	}
+
+	fallbackID, _ := strconv.ParseInt(r.FormValue("fallback_user_id"), 10, 64)
+	fallback, err := h.DB.GetUserByID(fallbackID)
+	if err != nil {
+		fallback = middleware.GetUser(r)
+	}
+
+	file, header, err := r.FormFile("archive")
+	if err != nil {
+		h.AddFlash(r, "danger", "Choose an export ZIP to import")
+		http.Redirect(w, r, "/admin/import", http.StatusSeeOther)
+		return
+	}
+	defer file.Close()
+
+	a, err := archive.ReadZip(file, header.Size)
+	if err != nil {
+		h.AddFlash(r, "danger", "Not a Lexicon export: "+err.Error())
+		http.Redirect(w, r, "/admin/import", http.StatusSeeOther)
+		return
+	}
+
+	report, err := archive.Import(h.DB, a, archive.Options{
+		Fallback: fallback,
+		Policy:   policy,
+		DryRun:   r.FormValue("dry_run") == "true",
+	})
+	if err != nil {
+		h.AddFlash(r, "danger", "Import stopped: "+err.Error())
+	}
+	h.renderImport(w, r, report)
+}
+
+func (h *Handler) renderImport(w http.ResponseWriter, r *http.Request, report *archive.Report) {
+	users, err := h.DB.ListUsers()
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	h.Render(w, r, "admin/import.html", "Import", map[string]any{
+		"Users":  users,
+		"Report": report,
+	})
+}
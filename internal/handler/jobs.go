@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+
+	"lexicon/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminJobs lists the background jobs and how their last runs went.
+func (h *Handler) AdminJobs(w http.ResponseWriter, r *http.Request) {
+	var jobs []scheduler.Status
+	if h.Scheduler != nil {
+		var err error
+		jobs, err = h.Scheduler.Jobs()
+		if err != nil {
+			h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	h.Render(w, r, "admin/jobs.html", "Background Jobs", map[string]any{
+		"Jobs": jobs,
+	})
+}
+
+// AdminRunJob starts a background job immediately.
+func (h *Handler) AdminRunJob(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if h.Scheduler == nil {
+		h.NotFound(w, r)
+		return
+	}
+
+	switch err := h.Scheduler.RunNow(name); err {
+	case nil:
+		h.AddFlash(r, "success", "Started job "+name)
+	case scheduler.ErrUnknownJob:
+		h.NotFound(w, r)
+		return
+	case scheduler.ErrJobRunning:
+		h.AddFlash(r, "warning", "Job "+name+" is already running")
+	default:
+		h.AddFlash(r, "danger", "Failed to start job "+name)
+	}
+	http.Redirect(w, r, "/admin/jobs", http.StatusSeeOther)
+}
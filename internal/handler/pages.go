@@ -2,11 +2,16 @@ package handler
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"lexicon/internal/database"
+	"lexicon/internal/diff"
 	"lexicon/internal/markdown"
 	"lexicon/internal/middleware"
+	"lexicon/internal/webhook"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -22,10 +27,20 @@ func (h *Handler) Home(w http.ResponseWriter, r *http.Request) {
 		"PhantomCount": phantomCount,
 	}
 
+	// Game state
+	if round, err := h.DB.CurrentRound(); err == nil {
+		data["CurrentRound"] = round
+		if round.Deadline != nil {
+			data["TimeLeft"] = timeUntil(*round.Deadline)
+		}
+	} else if round, err := h.DB.NextRound(); err == nil {
+		data["NextRound"] = round
+	}
+
 	// Check for a "home-page" wiki page to display as main content
 	if homePage, err := h.DB.GetPageBySlug("home-page"); err == nil && !homePage.IsPhantom {
 		if revision, err := h.DB.GetCurrentRevision(homePage.ID); err == nil {
-			if html, err := h.Markdown.Render(revision.Content); err == nil {
+			if html, err := h.renderFor(r, revision.Content); err == nil {
 				data["HomePageContent"] = html
 				data["HomePageExists"] = true
 			}
@@ -41,6 +56,12 @@ func (h *Handler) ViewPage(w http.ResponseWriter, r *http.Request) {
 
 	page, err := h.DB.GetPageBySlug(slug)
 	if err == database.ErrNotFound {
+		// Follow an alias or the redirect left behind by a move
+		if target, err := h.DB.ResolveRedirect(slug); err == nil {
+			http.Redirect(w, r, "/"+target.Slug+"?from="+url.QueryEscape(slug), http.StatusFound)
+			return
+		}
+
 		// Page doesn't exist - redirect to edit if logged in
 		if middleware.IsLoggedIn(r) {
 			http.Redirect(w, r, "/"+slug+"/edit", http.StatusSeeOther)
@@ -54,11 +75,20 @@ func (h *Handler) ViewPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.DB.CanViewSealed(page, middleware.GetUser(r)) {
+		h.renderSealed(w, r, page)
+		return
+	}
+
 	if page.IsPhantom {
-		// Phantom page - redirect to edit if logged in
-		if middleware.IsLoggedIn(r) {
-			http.Redirect(w, r, "/"+slug+"/edit", http.StatusSeeOther)
-			return
+		// Phantom page - redirect to edit if logged in, unless someone
+		// else has claimed it
+		if user := middleware.GetUser(r); user != nil {
+			claim, err := h.DB.GetClaim(page.ID)
+			if err != nil || claim.UserID == user.ID {
+				http.Redirect(w, r, "/"+slug+"/edit", http.StatusSeeOther)
+				return
+			}
 		}
 		h.renderPhantom(w, r, page)
 		return
@@ -70,6 +100,13 @@ func (h *Handler) ViewPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Follow #REDIRECT pages, but only one hop so redirects can't loop
+	query := r.URL.Query()
+	if page.RedirectTo != "" && page.RedirectTo != page.Slug && query.Get("redirect") != "no" && query.Get("from") == "" {
+		http.Redirect(w, r, "/"+page.RedirectTo+"?from="+url.QueryEscape(slug), http.StatusFound)
+		return
+	}
+
 	// Get current revision
 	revision, err := h.DB.GetCurrentRevision(page.ID)
 	if err != nil {
@@ -78,25 +115,63 @@ func (h *Handler) ViewPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Render markdown
-	html, err := h.Markdown.Render(revision.Content)
+	html, err := h.renderFor(r, revision.Content)
 	if err != nil {
 		h.RenderError(w, r, http.StatusInternalServerError, "Markdown error")
 		return
 	}
 
-	// Get comments
+	// Get comments and pages citing this one
 	comments, _ := h.DB.ListComments(page.ID)
 	revisionCount, _ := h.DB.RevisionCount(page.ID)
+	backlinks, _ := h.DB.ListBacklinks(page.Slug)
+	aliases := h.pageAliases(page.ID)
+
+	var round *database.Round
+	if page.RoundID != nil {
+		round, _ = h.DB.GetRound(*page.RoundID)
+	}
 
 	h.Render(w, r, "page/view.html", page.Title, map[string]any{
-		"Page":          page,
-		"Content":       html,
-		"Revision":      revision,
-		"Comments":      comments,
-		"RevisionCount": revisionCount,
+		"Page":           page,
+		"Content":        html,
+		"Revision":       revision,
+		"Comments":       comments,
+		"RevisionCount":  revisionCount,
+		"Backlinks":      backlinks,
+		"Aliases":        aliases,
+		"Round":          round,
+		"RedirectedFrom": h.redirectedFrom(query.Get("from"), page),
 	})
 }
 
+// redirectedFrom returns the slug the reader was redirected from, if it
+// really redirects to page.
+func (h *Handler) redirectedFrom(from string, page *database.Page) string {
+	if from == "" {
+		return ""
+	}
+	if target, err := h.DB.ResolveRedirect(from); err == nil && target.ID == page.ID {
+		return from
+	}
+	if source, err := h.DB.GetPageBySlug(from); err == nil && source.RedirectTo == page.Slug {
+		return from
+	}
+	return ""
+}
+
+// pageAliases returns the alias titles of a page.
+func (h *Handler) pageAliases(pageID int64) []string {
+	redirects, _ := h.DB.ListRedirects(pageID)
+	var aliases []string
+	for _, redirect := range redirects {
+		if redirect.IsAlias {
+			aliases = append(aliases, redirect.Title)
+		}
+	}
+	return aliases
+}
+
 func (h *Handler) renderPhantom(w http.ResponseWriter, r *http.Request, page *database.Page) {
 	var citedByUser *database.User
 	var citedInPage *database.Page
@@ -108,11 +183,18 @@ func (h *Handler) renderPhantom(w http.ResponseWriter, r *http.Request, page *da
 		citedInPage, _ = h.DB.GetPageByID(*page.FirstCitedInPageID)
 	}
 
+	backlinks, _ := h.DB.ListBacklinks(page.Slug)
+	claim, _ := h.DB.GetClaim(page.ID)
+
+	user := middleware.GetUser(r)
 	h.Render(w, r, "page/phantom.html", page.Title, map[string]any{
-		"Page":         page,
-		"CitedByUser":  citedByUser,
-		"CitedInPage":  citedInPage,
-		"CanEdit":      middleware.IsLoggedIn(r),
+		"Page":        page,
+		"CitedByUser": citedByUser,
+		"CitedInPage": citedInPage,
+		"Backlinks":   backlinks,
+		"Claim":       claim,
+		"CanEdit":     user != nil && (claim == nil || claim.UserID == user.ID),
+		"CanRelease":  user != nil && claim != nil && (claim.UserID == user.ID || user.IsAdmin()),
 	})
 }
 
@@ -133,29 +215,44 @@ func (h *Handler) EditPage(w http.ResponseWriter, r *http.Request) {
 	page, err := h.DB.GetPageBySlug(slug)
 
 	var title, content string
+	var baseRevisionID int64
+	var aliases []string
+	var claim *database.Claim
 	if err == database.ErrNotFound {
 		// New page - use slug as initial title
 		title = slug
 	} else if err != nil {
 		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
 		return
+	} else if !h.DB.CanViewSealed(page, middleware.GetUser(r)) {
+		h.AddFlash(r, "warning", sealedMessage)
+		http.Redirect(w, r, "/"+slug, http.StatusSeeOther)
+		return
 	} else if page.IsPhantom {
 		// Phantom page - use phantom's title
 		title = page.Title
+		claim, _ = h.DB.GetClaim(page.ID)
 	} else {
 		// Existing page - load current content
 		title = page.Title
 		rev, err := h.DB.GetCurrentRevision(page.ID)
 		if err == nil {
 			content = rev.Content
+			baseRevisionID = rev.ID
 		}
+		aliases = h.pageAliases(page.ID)
 	}
 
 	h.Render(w, r, "page/edit.html", "Edit: "+title, map[string]any{
-		"Slug":    slug,
-		"Title":   title,
-		"Content": content,
-		"IsNew":   page == nil || page.IsPhantom,
+		"Slug":           slug,
+		"Title":          title,
+		"Content":        content,
+		"IsNew":          page == nil || page.IsPhantom,
+		"BaseRevisionID": baseRevisionID,
+		"Aliases":        strings.Join(aliases, "\n"),
+		"IsPhantom":      page != nil && page.IsPhantom,
+		"Claim":          claim,
+		"ClaimedByOther": claim != nil && claim.UserID != middleware.GetUser(r).ID,
 	})
 }
 
@@ -166,6 +263,10 @@ func (h *Handler) SavePage(w http.ResponseWriter, r *http.Request) {
 
 	title := r.FormValue("title")
 	content := r.FormValue("content")
+	meta := database.RevisionMeta{
+		Summary: strings.TrimSpace(r.FormValue("summary")),
+		IsMinor: r.FormValue("minor") == "1",
+	}
 
 	if title == "" {
 		h.AddFlash(r, "danger", "Title is required")
@@ -184,11 +285,36 @@ func (h *Handler) SavePage(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/"+slug+"/edit", http.StatusSeeOther)
 		return
 	}
+	if len(meta.Summary) > 300 {
+		h.AddFlash(r, "danger", "Edit summary is too long (max 300 characters)")
+		http.Redirect(w, r, "/"+slug+"/edit", http.StatusSeeOther)
+		return
+	}
+
+	var links []markdown.LinkInfo
+	event := webhook.PageUpdated
 
 	page, err := h.DB.GetPageBySlug(slug)
+	if page != nil && !h.DB.CanViewSealed(page, user) {
+		h.AddFlash(r, "warning", sealedMessage)
+		http.Redirect(w, r, "/"+slug, http.StatusSeeOther)
+		return
+	}
 	if err == database.ErrNotFound || (page != nil && page.IsPhantom) {
 		// Create new page
-		page, err = h.DB.CreatePage(slug, title, content, user.ID)
+		// A new entry is never a minor edit
+		meta.IsMinor = false
+		links = h.Markdown.ExtractLinks(content)
+		if !h.checkRules(w, r, slug, page, title, content, meta, links) {
+			return
+		}
+		event = webhook.PageCreated
+		page, err = h.DB.CreatePage(slug, title, content, user.ID, markdown.DatabaseLinks(links), meta)
+		if err == database.ErrClaimed {
+			h.AddFlash(r, "danger", "Someone else has claimed this entry")
+			http.Redirect(w, r, "/"+slug, http.StatusSeeOther)
+			return
+		}
 		if err != nil {
 			h.AddFlash(r, "danger", "Failed to create page")
 			http.Redirect(w, r, "/"+slug+"/edit", http.StatusSeeOther)
@@ -198,8 +324,19 @@ func (h *Handler) SavePage(w http.ResponseWriter, r *http.Request) {
 		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
 		return
 	} else {
+		// Merge in edits saved since the form was loaded
+		var ok bool
+		content, ok = h.mergeConcurrentEdit(w, r, page, title, content, meta)
+		if !ok {
+			return
+		}
+
 		// Update existing page
-		err = h.DB.UpdatePage(page.ID, title, content, user.ID)
+		links = h.Markdown.ExtractLinks(content)
+		if !h.checkRules(w, r, slug, page, title, content, meta, links) {
+			return
+		}
+		err = h.DB.UpdatePage(page.ID, title, content, user.ID, markdown.DatabaseLinks(links), meta)
 		if err != nil {
 			h.AddFlash(r, "danger", "Failed to update page")
 			http.Redirect(w, r, "/"+slug+"/edit", http.StatusSeeOther)
@@ -207,16 +344,81 @@ func (h *Handler) SavePage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Process wiki links and create phantoms
-	h.processWikiLinks(content, user.ID, page.ID)
+	// Create phantoms for cited pages that don't exist yet
+	phantoms := h.processWikiLinks(links, user.ID, page.ID)
+
+	h.emitPageSaved(r, event, page.ID, user)
+	h.emitPhantoms(r, phantoms, page.ID, user)
 
 	h.AddFlash(r, "success", "Page saved")
+
+	if _, ok := r.PostForm["aliases"]; ok {
+		rejected, err := h.DB.SetAliases(page.ID, strings.Split(r.FormValue("aliases"), "\n"))
+		if err != nil {
+			h.AddFlash(r, "danger", "Failed to save aliases")
+		} else if len(rejected) > 0 {
+			h.AddFlash(r, "warning", "These aliases are already used by other pages: "+strings.Join(rejected, ", "))
+		}
+	}
+
 	http.Redirect(w, r, "/"+slug, http.StatusSeeOther)
 }
 
-func (h *Handler) processWikiLinks(content string, userID, pageID int64) {
-	links := h.Markdown.ExtractLinks(content)
+// mergeConcurrentEdit checks whether a page gained revisions after the edit
+// form was loaded (per its base_revision_id field). Such edits are merged
+// with the submitted content when possible; otherwise the conflict screen is
+// rendered and false is returned.
+func (h *Handler) mergeConcurrentEdit(w http.ResponseWriter, r *http.Request, page *database.Page, title, content string, meta database.RevisionMeta) (string, bool) {
+	baseValues, present := r.PostForm["base_revision_id"]
+	if !present || len(baseValues) == 0 {
+		return content, true
+	}
+
+	current, err := h.DB.GetCurrentRevision(page.ID)
+	if err != nil {
+		return content, true
+	}
+
+	// A base of 0 means the page didn't exist when the form was loaded
+	var baseContent string
+	if baseID := baseValues[0]; baseID != "" && baseID != "0" {
+		base, err := h.pageRevision(page, baseID)
+		if err != nil {
+			h.RenderError(w, r, http.StatusBadRequest, "Invalid base revision")
+			return "", false
+		}
+		if base.ID == current.ID {
+			return content, true
+		}
+		baseContent = base.Content
+	}
+
+	if merged, ok := diff.Merge3(baseContent, content, current.Content); ok {
+		h.AddFlash(r, "info", "This page was edited by "+current.AuthorUsername+" while you were editing. Your changes were merged automatically.")
+		return merged, true
+	}
+
+	lines := diff.Lines(current.Content, content)
+	w.WriteHeader(http.StatusConflict)
+	h.Render(w, r, "page/conflict.html", "Edit conflict: "+page.Title, map[string]any{
+		"Page":           page,
+		"Title":          title,
+		"Content":        content,
+		"Summary":        meta.Summary,
+		"IsMinor":        meta.IsMinor,
+		"Aliases":        r.FormValue("aliases"),
+		"Current":        current,
+		"Hunks":          diff.Hunks(lines, 3),
+		"BaseRevisionID": current.ID,
+	})
+	return "", false
+}
+
+// processWikiLinks creates phantoms for cited pages that don't exist yet,
+// and returns them.
+func (h *Handler) processWikiLinks(links []markdown.LinkInfo, userID, pageID int64) []*database.Page {
 	targets := markdown.UniqueTargets(links)
+	var created []*database.Page
 
 	for _, target := range targets {
 		exists, err := h.DB.PageExists(target)
@@ -233,8 +435,11 @@ func (h *Handler) processWikiLinks(content string, userID, pageID int64) {
 			}
 		}
 
-		h.DB.CreatePhantom(target, displayText, userID, pageID)
+		if phantom, err := h.DB.CreatePhantom(target, displayText, userID, pageID); err == nil {
+			created = append(created, phantom)
+		}
 	}
+	return created
 }
 
 // PageHistory shows revision history.
@@ -242,7 +447,7 @@ func (h *Handler) PageHistory(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "slug")
 
 	page, err := h.DB.GetPageBySlug(slug)
-	if err == database.ErrNotFound {
+	if err == database.ErrNotFound || (page != nil && !h.DB.CanViewSealed(page, middleware.GetUser(r))) {
 		h.NotFound(w, r)
 		return
 	}
@@ -257,9 +462,124 @@ func (h *Handler) PageHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var oldestID int64
+	if len(revisions) > 0 {
+		oldestID = revisions[len(revisions)-1].ID
+	}
+
 	h.Render(w, r, "page/history.html", "History: "+page.Title, map[string]any{
 		"Page":      page,
 		"Revisions": revisions,
+		"OldestID":  oldestID,
+	})
+}
+
+// PageDiff shows the differences between two revisions of a page.
+// Without "from", the revision preceding "to" is used; without "to", the
+// current revision is used.
+func (h *Handler) PageDiff(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+
+	page, err := h.DB.GetPageBySlug(slug)
+	if err == database.ErrNotFound || (page != nil && !h.DB.CanViewSealed(page, middleware.GetUser(r))) {
+		h.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var to *database.Revision
+	if toID := r.URL.Query().Get("to"); toID != "" {
+		to, err = h.pageRevision(page, toID)
+	} else {
+		to, err = h.DB.GetCurrentRevision(page.ID)
+	}
+	if err == database.ErrNotFound {
+		h.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var from *database.Revision
+	if fromID := r.URL.Query().Get("from"); fromID != "" {
+		from, err = h.pageRevision(page, fromID)
+		if err == database.ErrNotFound {
+			h.NotFound(w, r)
+			return
+		}
+	} else {
+		from, err = h.DB.GetPreviousRevision(to)
+		if err == database.ErrNotFound {
+			err = nil // First revision: compare against an empty page
+		}
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var fromContent string
+	if from != nil {
+		fromContent = from.Content
+	}
+	lines := diff.Lines(fromContent, to.Content)
+	added, removed := diff.Stats(lines)
+
+	h.Render(w, r, "page/diff.html", "Changes: "+page.Title, map[string]any{
+		"Page":    page,
+		"From":    from,
+		"To":      to,
+		"Hunks":   diff.Hunks(lines, 3),
+		"Added":   added,
+		"Removed": removed,
+	})
+}
+
+// pageRevision loads a revision by its ID string and verifies it belongs to the page.
+func (h *Handler) pageRevision(page *database.Page, revisionID string) (*database.Revision, error) {
+	var revID int64
+	if _, err := fmt.Sscanf(revisionID, "%d", &revID); err != nil {
+		return nil, database.ErrNotFound
+	}
+
+	revision, err := h.DB.GetRevisionByID(revID)
+	if err != nil {
+		return nil, err
+	}
+	if revision.PageID != page.ID {
+		return nil, database.ErrNotFound
+	}
+	return revision, nil
+}
+
+// PageBacklinks lists the pages that cite a page ("What links here").
+func (h *Handler) PageBacklinks(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+
+	page, err := h.DB.GetPageBySlug(slug)
+	if err == database.ErrNotFound || (page != nil && !h.DB.CanViewSealed(page, middleware.GetUser(r))) {
+		h.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	backlinks, err := h.DB.ListBacklinks(page.Slug)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	h.Render(w, r, "page/backlinks.html", "What links here: "+page.Title, map[string]any{
+		"Page":      page,
+		"Backlinks": backlinks,
 	})
 }
 
@@ -269,7 +589,7 @@ func (h *Handler) ViewRevision(w http.ResponseWriter, r *http.Request) {
 	revisionID := chi.URLParam(r, "revisionID")
 
 	page, err := h.DB.GetPageBySlug(slug)
-	if err == database.ErrNotFound {
+	if err == database.ErrNotFound || (page != nil && !h.DB.CanViewSealed(page, middleware.GetUser(r))) {
 		h.NotFound(w, r)
 		return
 	}
@@ -300,15 +620,77 @@ func (h *Handler) ViewRevision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	html, _ := h.Markdown.Render(revision.Content)
+	html, _ := h.renderFor(r, revision.Content)
+
+	isCurrent := false
+	if current, err := h.DB.GetCurrentRevision(page.ID); err == nil {
+		isCurrent = current.ID == revision.ID
+	}
 
 	h.Render(w, r, "page/revision.html", "Revision: "+page.Title, map[string]any{
-		"Page":     page,
-		"Revision": revision,
-		"Content":  html,
+		"Page":      page,
+		"Revision":  revision,
+		"Content":   html,
+		"IsCurrent": isCurrent,
 	})
 }
 
+// RevertRevision restores a page to the content of an earlier revision by
+// adding a new revision that records which one it reverts to.
+func (h *Handler) RevertRevision(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	user := middleware.GetUser(r)
+
+	page, err := h.DB.GetPageBySlug(slug)
+	if err == database.ErrNotFound || (page != nil && (page.IsPhantom || page.DeletedAt != nil || !h.DB.CanViewSealed(page, user))) {
+		h.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	revision, err := h.pageRevision(page, chi.URLParam(r, "revisionID"))
+	if err == database.ErrNotFound {
+		h.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	current, err := h.DB.GetCurrentRevision(page.ID)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if current.ID == revision.ID {
+		h.AddFlash(r, "info", "That revision is already the current version")
+		http.Redirect(w, r, "/"+slug, http.StatusSeeOther)
+		return
+	}
+
+	links := h.Markdown.ExtractLinks(revision.Content)
+	err = h.DB.UpdatePage(page.ID, page.Title, revision.Content, user.ID, markdown.DatabaseLinks(links), database.RevisionMeta{
+		RevertOfID: &revision.ID,
+	})
+	if err != nil {
+		h.AddFlash(r, "danger", "Failed to revert page")
+		http.Redirect(w, r, fmt.Sprintf("/%s/revision/%d", slug, revision.ID), http.StatusSeeOther)
+		return
+	}
+
+	phantoms := h.processWikiLinks(links, user.ID, page.ID)
+
+	h.emitPageSaved(r, webhook.PageUpdated, page.ID, user)
+	h.emitPhantoms(r, phantoms, page.ID, user)
+
+	h.AddFlash(r, "success", fmt.Sprintf("Page reverted to revision #%d", revision.ID))
+	http.Redirect(w, r, "/"+slug, http.StatusSeeOther)
+}
+
 // ListPages shows all pages.
 func (h *Handler) ListPages(w http.ResponseWriter, r *http.Request) {
 	pages, err := h.DB.ListPages()
@@ -335,16 +717,19 @@ func (h *Handler) ListPhantoms(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// RecentPages shows recently modified pages.
+// RecentPages shows the latest edits, optionally hiding minor ones.
 func (h *Handler) RecentPages(w http.ResponseWriter, r *http.Request) {
-	pages, err := h.DB.ListRecentPages(50)
+	hideMinor := r.URL.Query().Get("hide_minor") == "1"
+
+	edits, err := h.DB.ListRecentEdits(50, !hideMinor)
 	if err != nil {
 		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
 		return
 	}
 
-	h.Render(w, r, "pages/recent.html", "Recent Pages", map[string]any{
-		"Pages": pages,
+	h.Render(w, r, "pages/recent.html", "Recent Changes", map[string]any{
+		"Edits":     edits,
+		"HideMinor": hideMinor,
 	})
 }
 
@@ -354,7 +739,7 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 
 	page, err := h.DB.GetPageBySlug(slug)
-	if err == database.ErrNotFound {
+	if err == database.ErrNotFound || (page != nil && !h.DB.CanViewSealed(page, middleware.GetUser(r))) {
 		h.NotFound(w, r)
 		return
 	}
@@ -376,10 +761,11 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = h.DB.CreateComment(page.ID, user.ID, content)
+	comment, err := h.DB.CreateComment(page.ID, user.ID, content)
 	if err != nil {
 		h.AddFlash(r, "danger", "Failed to add comment")
 	} else {
+		h.emitComment(r, page, comment, user)
 		h.AddFlash(r, "success", "Comment added")
 	}
 
@@ -413,7 +799,133 @@ func (h *Handler) DeletePage(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/"+slug, http.StatusSeeOther)
 		return
 	}
+	h.emitPageEvent(r, webhook.PageDeleted, page, user)
 
 	h.AddFlash(r, "success", "Page deleted")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+// MovePageForm renders the form for renaming a page.
+func (h *Handler) MovePageForm(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.movablePage(w, r)
+	if !ok {
+		return
+	}
+
+	redirects, _ := h.DB.ListRedirects(page.ID)
+	backlinks, _ := h.DB.ListBacklinks(page.Slug)
+
+	h.Render(w, r, "page/move.html", "Move: "+page.Title, map[string]any{
+		"Page":      page,
+		"Redirects": redirects,
+		"Backlinks": backlinks,
+	})
+}
+
+// MovePage changes a page's title and slug, leaving a redirect at the old
+// slug and optionally rewriting links in citing pages.
+func (h *Handler) MovePage(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	page, ok := h.movablePage(w, r)
+	if !ok {
+		return
+	}
+
+	newTitle := strings.TrimSpace(r.FormValue("title"))
+	newSlug := database.Slugify(newTitle)
+	if newSlug == "" || len(newTitle) > 500 {
+		h.AddFlash(r, "danger", "Enter a valid new title")
+		http.Redirect(w, r, "/"+page.Slug+"/move", http.StatusSeeOther)
+		return
+	}
+	if newSlug == page.Slug {
+		h.AddFlash(r, "danger", "The new title maps to the same address. Change the title from the edit form instead.")
+		http.Redirect(w, r, "/"+page.Slug+"/move", http.StatusSeeOther)
+		return
+	}
+
+	// Collect citing pages before the move, while links still match the old slug
+	oldSlug, oldTitle := page.Slug, page.Title
+	citing, _ := h.DB.ListBacklinks(oldSlug)
+
+	err := h.DB.MovePage(page.ID, newSlug, newTitle)
+	if err == database.ErrSlugTaken {
+		h.AddFlash(r, "danger", "Another page already uses that address")
+		http.Redirect(w, r, "/"+oldSlug+"/move", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		h.AddFlash(r, "danger", "Failed to move page")
+		http.Redirect(w, r, "/"+oldSlug+"/move", http.StatusSeeOther)
+		return
+	}
+
+	rewritten := 0
+	if r.FormValue("rewrite_links") == "1" {
+		meta := database.RevisionMeta{
+			Summary: fmt.Sprintf("Update links: %s moved to %s", oldTitle, newTitle),
+			IsMinor: true,
+		}
+		for _, source := range citing {
+			if h.rewriteLinks(source.ID, oldSlug, newTitle, user.ID, meta) {
+				rewritten++
+			}
+		}
+	}
+
+	msg := fmt.Sprintf("Page moved to %s", newTitle)
+	if rewritten > 0 {
+		msg += fmt.Sprintf(" and links updated in %d %s", rewritten, pluralize(rewritten, "page", "pages"))
+	}
+	h.AddFlash(r, "success", msg)
+	http.Redirect(w, r, "/"+newSlug, http.StatusSeeOther)
+}
+
+// rewriteLinks saves a new revision of a page with its links to oldSlug
+// pointing at newTarget. Reports whether anything changed.
+func (h *Handler) rewriteLinks(pageID int64, oldSlug, newTarget string, userID int64, meta database.RevisionMeta) bool {
+	// Reload the page, whose title may have changed if it cites itself
+	page, err := h.DB.GetPageByID(pageID)
+	if err != nil {
+		return false
+	}
+	rev, err := h.DB.GetCurrentRevision(page.ID)
+	if err != nil {
+		return false
+	}
+
+	content, count := markdown.RewriteLinks(rev.Content, oldSlug, newTarget)
+	if count == 0 {
+		return false
+	}
+
+	links := h.Markdown.ExtractLinks(content)
+	if err := h.DB.UpdatePage(page.ID, page.Title, content, userID, markdown.DatabaseLinks(links), meta); err != nil {
+		log.Printf("Failed to rewrite links in %s: %v", page.Slug, err)
+		return false
+	}
+	h.processWikiLinks(links, userID, page.ID)
+	return true
+}
+
+// movablePage loads the live, non-phantom page named in the URL.
+func (h *Handler) movablePage(w http.ResponseWriter, r *http.Request) (*database.Page, bool) {
+	page, err := h.DB.GetPageBySlug(chi.URLParam(r, "slug"))
+	if err == database.ErrNotFound || (page != nil && (page.IsPhantom || page.DeletedAt != nil)) {
+		h.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	return page, true
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
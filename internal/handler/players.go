@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"lexicon/internal/database"
+	"lexicon/internal/middleware"
+)
+
+// Account renders the player dashboard: persona, this round's entry and
+// past assignments.
+func (h *Handler) Account(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	player, err := h.DB.GetPlayerByUserID(user.ID)
+	if err != nil && err != database.ErrNotFound {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	round, _ := h.DB.CurrentRound()
+
+	var current *database.Assignment
+	var assignments []*database.Assignment
+	if player != nil {
+		if round != nil {
+			current, _ = h.DB.GetAssignment(player.ID, round.ID)
+		}
+		assignments, _ = h.DB.ListPlayerAssignments(player.ID)
+	}
+
+	data := map[string]any{
+		"Player":      player,
+		"Round":       round,
+		"Current":     current,
+		"Assignments": assignments,
+	}
+	if round != nil && round.Deadline != nil {
+		data["TimeLeft"] = timeUntil(*round.Deadline)
+	}
+
+	h.Render(w, r, "account/dashboard.html", "Your Account", data)
+}
+
+// SavePersona updates the current user's scholar persona.
+func (h *Handler) SavePersona(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	displayName := strings.TrimSpace(r.FormValue("display_name"))
+	if displayName == "" {
+		h.AddFlash(r, "danger", "Display name is required")
+		http.Redirect(w, r, "/account", http.StatusSeeOther)
+		return
+	}
+	bioSlug := database.Slugify(strings.TrimSpace(r.FormValue("bio")))
+
+	if _, err := h.DB.SavePlayer(user.ID, displayName, bioSlug); err != nil {
+		h.AddFlash(r, "danger", "Failed to save persona")
+	} else {
+		h.AddFlash(r, "success", "Persona saved")
+	}
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+// AdminAssignments renders the assignment table for one round.
+func (h *Handler) AdminAssignments(w http.ResponseWriter, r *http.Request) {
+	rounds, err := h.DB.ListRounds()
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	round := selectedRound(r, rounds)
+	if round == nil {
+		h.Render(w, r, "admin/assignments.html", "Assignments", map[string]any{})
+		return
+	}
+
+	users, err := h.DB.ListUsers()
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+	players, _ := h.DB.ListPlayers()
+	assignments, _ := h.DB.ListRoundAssignments(round.ID)
+
+	playerByUser := make(map[int64]*database.Player)
+	for _, p := range players {
+		playerByUser[p.UserID] = p
+	}
+	assignmentByPlayer := make(map[int64]*database.Assignment)
+	for _, a := range assignments {
+		assignmentByPlayer[a.PlayerID] = a
+	}
+
+	type row struct {
+		User       *database.User
+		Player     *database.Player
+		Assignment *database.Assignment
+	}
+	var rows []row
+	for _, u := range users {
+		rw := row{User: u, Player: playerByUser[u.ID]}
+		if rw.Player != nil {
+			rw.Assignment = assignmentByPlayer[rw.Player.ID]
+		}
+		rows = append(rows, rw)
+	}
+
+	h.Render(w, r, "admin/assignments.html", "Assignments", map[string]any{
+		"Rounds": rounds,
+		"Round":  round,
+		"Rows":   rows,
+	})
+}
+
+// AdminAssign sets or clears the entry a user is to write in a round.
+func (h *Handler) AdminAssign(w http.ResponseWriter, r *http.Request) {
+	roundID, _ := strconv.ParseInt(r.FormValue("round_id"), 10, 64)
+	userID, _ := strconv.ParseInt(r.FormValue("user_id"), 10, 64)
+	back := "/admin/assignments?round=" + strconv.FormatInt(roundID, 10)
+
+	round, err := h.DB.GetRound(roundID)
+	if err != nil {
+		h.AddFlash(r, "danger", "Round not found")
+		http.Redirect(w, r, "/admin/assignments", http.StatusSeeOther)
+		return
+	}
+
+	player, err := h.DB.EnsurePlayer(userID)
+	if err != nil {
+		h.AddFlash(r, "danger", "User not found")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
+	slug := database.Slugify(title)
+	if slug == "" {
+		title = ""
+	}
+
+	err = h.DB.AssignEntry(player.ID, round.ID, slug, title)
+	switch {
+	case err == database.ErrAlreadyAssigned:
+		h.AddFlash(r, "danger", title+" is already assigned to another player in round "+round.Label)
+	case err != nil:
+		h.AddFlash(r, "danger", "Failed to save assignment")
+	case slug == "":
+		h.AddFlash(r, "success", "Assignment for "+player.DisplayName+" removed")
+	default:
+		h.AddFlash(r, "success", player.DisplayName+" will write "+title+" in round "+round.Label)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// selectedRound picks the round named by ?round=, falling back to the open
+// round, then the next pending one, then the first.
+func selectedRound(r *http.Request, rounds []*database.Round) *database.Round {
+	if id, err := strconv.ParseInt(r.URL.Query().Get("round"), 10, 64); err == nil {
+		for _, round := range rounds {
+			if round.ID == id {
+				return round
+			}
+		}
+	}
+	for _, round := range rounds {
+		if round.IsOpen() {
+			return round
+		}
+	}
+	for _, round := range rounds {
+		if round.Status == database.RoundPending {
+			return round
+		}
+	}
+	if len(rounds) > 0 {
+		return rounds[0]
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+
+	"lexicon/internal/database"
+	"lexicon/internal/markdown"
+	"lexicon/internal/middleware"
+	"lexicon/internal/rules"
+)
+
+// checkRules evaluates the citation rules against a save. If the entry
+// breaks any, it renders the edit form again with the violations and returns
+// false. Admins can tick "save anyway" to override.
+func (h *Handler) checkRules(w http.ResponseWriter, r *http.Request, slug string, page *database.Page, title, content string, meta database.RevisionMeta, links []markdown.LinkInfo) bool {
+	user := middleware.GetUser(r)
+	if user.IsAdmin() && r.FormValue("override_rules") == "1" {
+		return true
+	}
+
+	violations, err := h.ruleViolations(user, page, links)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if len(violations) == 0 {
+		return true
+	}
+
+	isNew := page == nil || page.IsPhantom
+	var baseRevisionID int64
+	if !isNew {
+		if current, err := h.DB.GetCurrentRevision(page.ID); err == nil {
+			baseRevisionID = current.ID
+		}
+	}
+
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	h.Render(w, r, "page/edit.html", "Edit: "+title, map[string]any{
+		"Slug":           slug,
+		"Title":          title,
+		"Content":        content,
+		"Summary":        meta.Summary,
+		"IsMinor":        meta.IsMinor,
+		"IsNew":          isNew,
+		"BaseRevisionID": baseRevisionID,
+		"Aliases":        r.FormValue("aliases"),
+		"Violations":     violations,
+		"CanOverride":    user.IsAdmin(),
+	})
+	return false
+}
+
+// ruleViolations returns the citation rules a save by user would break.
+func (h *Handler) ruleViolations(user *database.User, page *database.Page, links []markdown.LinkInfo) ([]rules.Violation, error) {
+	settings, err := h.DB.GetAllSettings()
+	if err != nil {
+		return nil, err
+	}
+	cfg := rules.ConfigFromSettings(settings)
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	entry, err := h.ruleEntry(user, page, links)
+	if err != nil {
+		return nil, err
+	}
+	return rules.Evaluate(cfg, entry), nil
+}
+
+// ruleEntry describes a save for rule evaluation: who the entry belongs to
+// and what each citation points at, as far as the editor can see.
+func (h *Handler) ruleEntry(editor *database.User, page *database.Page, links []markdown.LinkInfo) (rules.Entry, error) {
+	entry := rules.Entry{AuthorID: editor.ID, EditorID: editor.ID}
+	if page != nil {
+		entry.PageID = page.ID
+		if page.IsPhantom {
+			if page.FirstCitedByUserID != nil {
+				entry.PhantomCreatorID = *page.FirstCitedByUserID
+			}
+		} else if author, err := h.DB.PageAuthor(page.ID); err == nil {
+			entry.AuthorID = author
+		}
+	}
+
+	for _, slug := range markdown.UniqueTargets(links) {
+		target := rules.Target{Title: slug}
+
+		cited, err := h.DB.ResolveCitation(slug)
+		switch {
+		case err == database.ErrNotFound:
+		case err != nil:
+			return entry, err
+		case cited.IsPhantom || !h.DB.CanViewSealed(cited, editor):
+			// A sealed entry is still a phantom to everyone but its author,
+			// so it mustn't give away that it was written, or by whom
+			target.Title = cited.Title
+			target.Sealed = !cited.IsPhantom
+			if cited.FirstCitedInPageID != nil {
+				target.FirstCitedInPageID = *cited.FirstCitedInPageID
+			}
+		default:
+			target.Title = cited.Title
+			target.Written = true
+			if target.AuthorID, err = h.DB.PageAuthor(cited.ID); err != nil && err != database.ErrNotFound {
+				return entry, err
+			}
+		}
+
+		entry.Targets = append(entry.Targets, target)
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+
+	"lexicon/internal/database"
+	"lexicon/internal/middleware"
+)
+
+const sealedMessage = "This entry is sealed until its round is published"
+
+// pageStatus reports whether a link target exists and whether it should be
+// shown as a phantom. Sealed entries are shown as phantoms.
+func (h *Handler) pageStatus(slug string) (exists, isPhantom bool) {
+	return h.pageStatusFor(slug, nil)
+}
+
+// pageStatusFor is pageStatus for a user, who sees the sealed entries they
+// may read as written. Aliases and redirects are judged by their target.
+func (h *Handler) pageStatusFor(slug string, user *database.User) (exists, isPhantom bool) {
+	page, err := h.DB.GetPageBySlug(slug)
+	if err == database.ErrNotFound {
+		page, err = h.DB.ResolveRedirect(slug)
+	}
+	if err != nil {
+		return false, false
+	}
+	return true, page.IsPhantom || !h.DB.CanViewSealed(page, user)
+}
+
+// renderFor renders markdown for the current user. Sealed entries the user
+// wrote, or any sealed entry for admins, are linked normally.
+func (h *Handler) renderFor(r *http.Request, content string) (string, error) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		return h.Markdown.Render(content)
+	}
+	return h.Markdown.RenderWith(content, func(slug string) (bool, bool) {
+		return h.pageStatusFor(slug, user)
+	})
+}
+
+// renderSealed shows a sealed entry to someone who may not read it yet, as
+// if it were still unwritten.
+func (h *Handler) renderSealed(w http.ResponseWriter, r *http.Request, page *database.Page) {
+	var round *database.Round
+	if page.RoundID != nil {
+		round, _ = h.DB.GetRound(*page.RoundID)
+	}
+
+	h.Render(w, r, "page/phantom.html", page.Title, map[string]any{
+		"Page":   page,
+		"Sealed": true,
+		"Round":  round,
+	})
+}
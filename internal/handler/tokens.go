@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lexicon/internal/database"
+	"lexicon/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// tokenExpiries are the lifetimes offered for new API tokens, in days.
+// Zero means the token never expires.
+var tokenExpiries = []struct {
+	Days  int
+	Label string
+}{
+	{30, "30 days"},
+	{90, "90 days"},
+	{365, "1 year"},
+	{0, "Never"},
+}
+
+// AccountTokens lists the user's API tokens.
+func (h *Handler) AccountTokens(w http.ResponseWriter, r *http.Request) {
+	h.renderTokens(w, r, "")
+}
+
+// CreateAPIToken issues a new API token and shows its secret, once.
+func (h *Handler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	if middleware.GetAPIToken(r) != nil {
+		h.RenderError(w, r, http.StatusForbidden, "API tokens can't be managed with an API token")
+		return
+	}
+	user := middleware.GetUser(r)
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	scope := r.FormValue("scope")
+	days, err := strconv.Atoi(r.FormValue("expires_in"))
+
+	if name == "" || len(name) > 100 {
+		h.AddFlash(r, "danger", "Token name must be between 1 and 100 characters")
+		http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+		return
+	}
+	if !database.ValidScope(scope) || (scope == database.ScopeAdmin && !user.IsAdmin()) {
+		h.AddFlash(r, "danger", "Invalid scope")
+		http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+		return
+	}
+	if err != nil || days < 0 {
+		h.AddFlash(r, "danger", "Invalid expiry")
+		http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+		return
+	}
+
+	var expiresAt *time.Time
+	if days > 0 {
+		t := time.Now().AddDate(0, 0, days)
+		expiresAt = &t
+	}
+
+	_, secret, err := h.DB.CreateAPIToken(user.ID, name, scope, expiresAt)
+	if err != nil {
+		h.AddFlash(r, "danger", "Failed to create token")
+		http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+		return
+	}
+
+	h.renderTokens(w, r, secret)
+}
+
+// DeleteAPIToken revokes one of the user's API tokens.
+func (h *Handler) DeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	if middleware.GetAPIToken(r) != nil {
+		h.RenderError(w, r, http.StatusForbidden, "API tokens can't be managed with an API token")
+		return
+	}
+	user := middleware.GetUser(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.NotFound(w, r)
+		return
+	}
+
+	switch err := h.DB.DeleteAPIToken(id, user.ID); err {
+	case nil:
+		h.AddFlash(r, "success", "Token revoked")
+	case database.ErrNotFound:
+		h.AddFlash(r, "danger", "Token not found")
+	default:
+		h.AddFlash(r, "danger", "Failed to revoke token")
+	}
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+// renderTokens shows the token list, with the secret of a token that was
+// just created.
+func (h *Handler) renderTokens(w http.ResponseWriter, r *http.Request, secret string) {
+	user := middleware.GetUser(r)
+
+	tokens, err := h.DB.ListAPITokens(user.ID)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	h.Render(w, r, "account/tokens.html", "API Tokens", map[string]any{
+		"Tokens":    tokens,
+		"NewSecret": secret,
+		"Expiries":  tokenExpiries,
+		"APIBase":   baseURL(r) + "/api/v1",
+	})
+}
@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"lexicon/internal/database"
+	"lexicon/internal/webhook"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const deliveryLogSize = 100
+
+// AdminWebhooks lists the webhooks, a form to add one and the latest
+// deliveries.
+func (h *Handler) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.DB.ListWebhooks()
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+	deliveries, err := h.DB.ListWebhookDeliveries(0, deliveryLogSize)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	h.Render(w, r, "admin/webhooks.html", "Webhooks", map[string]any{
+		"Webhooks":   hooks,
+		"Deliveries": deliveries,
+		"Events":     webhook.Events,
+	})
+}
+
+// AdminWebhook shows one webhook's settings and deliveries.
+func (h *Handler) AdminWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.adminWebhook(w, r)
+	if !ok {
+		return
+	}
+	h.renderWebhook(w, r, hook, "")
+}
+
+// AdminCreateWebhook registers a webhook and shows its secret, once. A
+// secret is generated if none is given.
+func (h *Handler) AdminCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	hookURL, secret, events, ok := h.webhookForm(r)
+	if !ok {
+		http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+		return
+	}
+	if secret == "" {
+		var err error
+		if secret, err = webhook.NewSecret(); err != nil {
+			h.AddFlash(r, "danger", "Failed to generate a secret")
+			http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+			return
+		}
+	}
+
+	hook, err := h.DB.CreateWebhook(hookURL, secret, events)
+	if err != nil {
+		h.AddFlash(r, "danger", "Failed to add webhook")
+		http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	h.renderWebhook(w, r, hook, secret)
+}
+
+// AdminUpdateWebhook saves a webhook's settings. The secret is replaced by
+// one given or generated, and shown once; otherwise it's kept.
+func (h *Handler) AdminUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.adminWebhook(w, r)
+	if !ok {
+		return
+	}
+	back := fmt.Sprintf("/admin/webhooks/%d", hook.ID)
+
+	hookURL, secret, events, ok := h.webhookForm(r)
+	if !ok {
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if secret == "" && r.FormValue("regenerate_secret") == "1" {
+		var err error
+		if secret, err = webhook.NewSecret(); err != nil {
+			h.AddFlash(r, "danger", "Failed to generate a secret")
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+	}
+	hook.URL = hookURL
+	hook.Events = events
+	hook.Active = r.FormValue("active") == "1"
+	if secret != "" {
+		hook.Secret = secret
+	}
+
+	if err := h.DB.UpdateWebhook(hook); err != nil {
+		h.AddFlash(r, "danger", "Failed to save webhook")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if secret == "" {
+		h.AddFlash(r, "success", "Webhook saved")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	h.renderWebhook(w, r, hook, secret)
+}
+
+// renderWebhook shows a webhook's settings and deliveries, with the secret
+// if it was just set.
+func (h *Handler) renderWebhook(w http.ResponseWriter, r *http.Request, hook *database.Webhook, newSecret string) {
+	deliveries, err := h.DB.ListWebhookDeliveries(hook.ID, deliveryLogSize)
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	h.Render(w, r, "admin/webhook.html", "Webhook", map[string]any{
+		"Webhook":    hook,
+		"NewSecret":  newSecret,
+		"Deliveries": deliveries,
+		"Events":     webhook.Events,
+	})
+}
+
+// AdminDeleteWebhook removes a webhook and its delivery log.
+func (h *Handler) AdminDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.adminWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.DeleteWebhook(hook.ID); err != nil {
+		h.AddFlash(r, "danger", "Failed to delete webhook")
+	} else {
+		h.AddFlash(r, "success", "Webhook deleted")
+	}
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// AdminTestWebhook sends a ping to a webhook.
+func (h *Handler) AdminTestWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.adminWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := webhook.SendPing(h.DB, hook); err != nil {
+		h.AddFlash(r, "danger", "Failed to queue test delivery")
+	} else {
+		h.deliverWebhooks()
+		h.AddFlash(r, "success", "Test delivery queued. Reload to see how it went.")
+	}
+	http.Redirect(w, r, fmt.Sprintf("/admin/webhooks/%d", hook.ID), http.StatusSeeOther)
+}
+
+// AdminRetryDelivery sends a delivery again, with a fresh set of attempts.
+func (h *Handler) AdminRetryDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		h.NotFound(w, r)
+		return
+	}
+
+	switch err := h.DB.RetryWebhookDelivery(id); err {
+	case nil:
+		h.deliverWebhooks()
+		h.AddFlash(r, "success", "Delivery queued again")
+	case database.ErrNotFound:
+		h.NotFound(w, r)
+		return
+	default:
+		h.AddFlash(r, "danger", "Failed to queue delivery")
+	}
+
+	back := r.FormValue("redirect")
+	if !strings.HasPrefix(back, "/admin/webhooks") {
+		back = "/admin/webhooks"
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// adminWebhook loads the webhook named in the URL.
+func (h *Handler) adminWebhook(w http.ResponseWriter, r *http.Request) (*database.Webhook, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		h.NotFound(w, r)
+		return nil, false
+	}
+	hook, err := h.DB.GetWebhook(id)
+	if err == database.ErrNotFound {
+		h.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		h.RenderError(w, r, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	return hook, true
+}
+
+// webhookForm validates the submitted webhook settings, adding a flash
+// message if they're invalid.
+func (h *Handler) webhookForm(r *http.Request) (hookURL, secret string, events []string, ok bool) {
+	hookURL = strings.TrimSpace(r.FormValue("url"))
+	secret = strings.TrimSpace(r.FormValue("secret"))
+
+	if secret != "" && len(secret) < webhook.MinSecretLength {
+		h.AddFlash(r, "danger", fmt.Sprintf("Secrets must be at least %d characters; leave it empty to generate one", webhook.MinSecretLength))
+		return "", "", nil, false
+	}
+
+	u, err := url.Parse(hookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		h.AddFlash(r, "danger", "Enter an http:// or https:// URL")
+		return "", "", nil, false
+	}
+
+	r.ParseForm()
+	for _, e := range r.PostForm["events"] {
+		if !webhook.ValidEvent(e) {
+			h.AddFlash(r, "danger", "Unknown event "+e)
+			return "", "", nil, false
+		}
+		events = append(events, e)
+	}
+	if len(events) == 0 {
+		h.AddFlash(r, "danger", "Choose at least one event")
+		return "", "", nil, false
+	}
+	return hookURL, secret, events, true
+}
+
+// emit queues a webhook event and wakes the delivery job. Failures are only
+// logged: a webhook must never fail the request that triggered it.
+func (h *Handler) emit(event, text string, data any) {
+	n, err := webhook.Emit(h.DB, event, text, data)
+	h.queued(event, n, err)
+}
+
+// queued handles the result of queueing n deliveries of an event, logging
+// a failure or waking the delivery job.
+func (h *Handler) queued(event string, n int, err error) {
+	if err != nil {
+		log.Printf("Webhook: failed to queue %s: %v", event, err)
+	} else if n > 0 {
+		h.deliverWebhooks()
+	}
+}
+
+// deliverWebhooks starts the delivery job now rather than at its next run.
+// If it's already running it picks up the new deliveries before it stops.
+func (h *Handler) deliverWebhooks() {
+	if h.Scheduler != nil {
+		h.Scheduler.RunNow(webhook.JobName)
+	}
+}
+
+func webhookPage(r *http.Request, page *database.Page) webhook.Page {
+	return webhook.NewPage(baseURL(r), page)
+}
+
+// emitPageSaved announces a page's new revision. Sealed entries stay secret
+// until their round is published, and are announced then.
+func (h *Handler) emitPageSaved(r *http.Request, event string, pageID int64, user *database.User) {
+	page, err := h.DB.GetPageByID(pageID)
+	if err != nil || page.Sealed {
+		return
+	}
+	n, err := webhook.EmitPageSaved(h.DB, baseURL(r), event, page, user.Username)
+	h.queued(event, n, err)
+}
+
+// emitPublished announces the entries of a round that was just published.
+func (h *Handler) emitPublished(r *http.Request, pageIDs []int64) {
+	n, err := webhook.EmitPublished(h.DB, baseURL(r), pageIDs)
+	h.queued(webhook.PageCreated, n, err)
+}
+
+// emitPhantoms announces phantoms created by a save of the citing page.
+func (h *Handler) emitPhantoms(r *http.Request, phantoms []*database.Page, citingID int64, user *database.User) {
+	if len(phantoms) == 0 {
+		return
+	}
+	citing, err := h.DB.GetPageByID(citingID)
+	if err != nil || citing.Sealed {
+		return
+	}
+
+	for _, phantom := range phantoms {
+		data := webhook.PhantomData{
+			Phantom: webhookPage(r, phantom),
+			CitedIn: webhookPage(r, citing),
+			User:    webhook.User{Username: user.Username},
+		}
+		text := fmt.Sprintf("%s cited a new phantom, %s, in %s: %s", user.Username, phantom.Title, citing.Title, data.Phantom.URL)
+		h.emit(webhook.PhantomCreated, text, data)
+	}
+}
+
+// emitPageEvent announces a page being deleted or restored.
+func (h *Handler) emitPageEvent(r *http.Request, event string, page *database.Page, user *database.User) {
+	if page.Sealed {
+		return
+	}
+	data := webhook.PageData{Page: webhookPage(r, page), User: &webhook.User{Username: user.Username}}
+
+	verb := "deleted"
+	if event == webhook.PageRestored {
+		verb = "restored"
+	}
+	h.emit(event, fmt.Sprintf("%s %s %s", user.Username, verb, page.Title), data)
+}
+
+// emitComment announces a new comment.
+func (h *Handler) emitComment(r *http.Request, page *database.Page, comment *database.Comment, user *database.User) {
+	if page.Sealed {
+		return
+	}
+	data := webhook.CommentData{
+		Page:    webhookPage(r, page),
+		Comment: webhook.Comment{ID: comment.ID, Content: comment.Content},
+		User:    webhook.User{Username: user.Username},
+	}
+	text := fmt.Sprintf("%s commented on %s: %s#comments", user.Username, page.Title, data.Page.URL)
+	h.emit(webhook.CommentAdded, text, data)
+}
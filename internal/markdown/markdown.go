@@ -3,12 +3,14 @@ package markdown
 import (
 	"bytes"
 
+	"lexicon/internal/database"
 	"lexicon/internal/markdown/wikilink"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
 	"github.com/yuin/goldmark/text"
 	"github.com/yuin/goldmark/util"
 )
@@ -21,31 +23,54 @@ type Renderer struct {
 
 // New creates a new markdown renderer with the given page checker.
 func New(pageChecker wikilink.PageChecker) *Renderer {
-	r := &Renderer{
+	return &Renderer{
+		md:          newGoldmark(pageChecker, nil),
 		pageChecker: pageChecker,
 	}
+}
 
-	// Create goldmark instance with wiki-link extension
-	r.md = goldmark.New(
+// newGoldmark creates a goldmark instance with the wiki-link extension.
+func newGoldmark(pageChecker wikilink.PageChecker, linkURL wikilink.LinkURL, opts ...renderer.Option) goldmark.Markdown {
+	opts = append(opts, renderer.WithNodeRenderers(
+		util.Prioritized(&wikilink.Renderer{PageChecker: pageChecker, LinkURL: linkURL}, 100),
+	))
+	return goldmark.New(
 		goldmark.WithParserOptions(
 			parser.WithInlineParsers(
 				util.Prioritized(&wikilink.Parser{}, 100),
 			),
 		),
-		goldmark.WithRendererOptions(
-			renderer.WithNodeRenderers(
-				util.Prioritized(wikilink.NewRenderer(pageChecker), 100),
-			),
-		),
+		goldmark.WithRendererOptions(opts...),
 	)
-
-	return r
 }
 
 // Render converts markdown content to HTML.
 func (r *Renderer) Render(content string) (string, error) {
+	return convert(r.md, content)
+}
+
+// RenderWith converts markdown content to HTML, styling links with the given
+// page checker instead of the default one. Used when link status depends on
+// who is reading.
+func (r *Renderer) RenderWith(content string, pageChecker wikilink.PageChecker) (string, error) {
+	return convert(newGoldmark(pageChecker, nil), content)
+}
+
+// RenderLinked converts markdown content to HTML with the given page
+// checker, pointing wiki links wherever linkURL says. Used to render pages
+// outside the running wiki.
+func (r *Renderer) RenderLinked(content string, pageChecker wikilink.PageChecker, linkURL wikilink.LinkURL) (string, error) {
+	return convert(newGoldmark(pageChecker, linkURL), content)
+}
+
+// RenderXHTML is RenderLinked producing well-formed XHTML, as EPUB requires.
+func (r *Renderer) RenderXHTML(content string, pageChecker wikilink.PageChecker, linkURL wikilink.LinkURL) (string, error) {
+	return convert(newGoldmark(pageChecker, linkURL, html.WithXHTML()), content)
+}
+
+func convert(md goldmark.Markdown, content string) (string, error) {
 	var buf bytes.Buffer
-	if err := r.md.Convert([]byte(content), &buf); err != nil {
+	if err := md.Convert([]byte(content), &buf); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
@@ -99,3 +124,21 @@ func UniqueTargets(links []LinkInfo) []string {
 	}
 	return targets
 }
+
+// RewriteLinks points every wiki link to oldSlug at newTarget, keeping
+// custom display text. Returns the new content and the number of links changed.
+func RewriteLinks(content, oldSlug, newTarget string) (string, int) {
+	return wikilink.Rewrite(content, oldSlug, newTarget)
+}
+
+// DatabaseLinks converts extracted links into records for the link table.
+func DatabaseLinks(links []LinkInfo) []database.Link {
+	records := make([]database.Link, 0, len(links))
+	for _, link := range links {
+		records = append(records, database.Link{
+			TargetSlug:  link.Target,
+			DisplayText: link.DisplayText,
+		})
+	}
+	return records
+}
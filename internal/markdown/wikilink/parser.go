@@ -22,7 +22,7 @@ func (p *Parser) Trigger() []byte {
 
 // Parse parses a wiki link [[target]] or [[target|display]].
 func (p *Parser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
-	line, segment := block.PeekLine()
+	line, _ := block.PeekLine()
 	if len(line) < 4 { // Minimum: [[x]]
 		return nil
 	}
@@ -75,8 +75,8 @@ func (p *Parser) Parse(parent ast.Node, block text.Reader, pc parser.Context) as
 		return nil
 	}
 
-	// Advance the reader past the wiki link
-	block.Advance(segment.Start + end + 2)
+	// Advance the reader past the wiki link (relative to the current position)
+	block.Advance(end + 2)
 
 	return NewWikiLink(slug, displayText)
 }
@@ -1,10 +1,14 @@
 package wikilink
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
 func TestParser(t *testing.T) {
@@ -106,3 +110,49 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParserMidLine(t *testing.T) {
+	reader := text.NewReader([]byte("See [[A]] and [[B]]."))
+	reader.Advance(len("See "))
+
+	node := (&Parser{}).Parse(nil, reader, parser.NewContext())
+	if node == nil {
+		t.Fatal("expected non-nil node")
+	}
+
+	rest, _ := reader.PeekLine()
+	if string(rest) != " and [[B]]." {
+		t.Errorf("reader left at %q, want %q", rest, " and [[B]].")
+	}
+}
+
+func TestParserSeveralLinks(t *testing.T) {
+	source := []byte("See [[Elves]], [[Dwarves|the dwarves]] and [[Gnomes]] too.")
+	md := goldmark.New(goldmark.WithParserOptions(
+		parser.WithInlineParsers(util.Prioritized(&Parser{}, 100)),
+	))
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var targets []string
+	var rest strings.Builder
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *WikiLink:
+			targets = append(targets, n.Target)
+			rest.WriteString("|")
+		case *ast.Text:
+			rest.Write(n.Segment.Value(source))
+		}
+		return ast.WalkContinue, nil
+	})
+
+	if got := strings.Join(targets, ","); got != "elves,dwarves,gnomes" {
+		t.Errorf("targets = %s, want elves,dwarves,gnomes", got)
+	}
+	if got := rest.String(); got != "See |, | and | too." {
+		t.Errorf("text around links = %q, want %q", got, "See |, | and | too.")
+	}
+}
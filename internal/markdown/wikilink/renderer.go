@@ -11,9 +11,15 @@ import (
 // PageChecker is a function that checks if a page exists and whether it's a phantom.
 type PageChecker func(slug string) (exists bool, isPhantom bool)
 
+// LinkURL returns the href for a link to the page with the given slug.
+type LinkURL func(slug string) string
+
 // Renderer renders WikiLink nodes to HTML.
 type Renderer struct {
 	PageChecker PageChecker
+
+	// LinkURL overrides where links point; by default they go to /slug.
+	LinkURL LinkURL
 }
 
 // NewRenderer creates a new WikiLink renderer.
@@ -42,13 +48,18 @@ func (r *Renderer) renderWikiLink(w util.BufWriter, source []byte, node ast.Node
 		}
 	}
 
+	href := "/" + n.Target
+	if r.LinkURL != nil {
+		href = r.LinkURL(n.Target)
+	}
+
 	// Escape values for HTML
-	escapedTarget := html.EscapeString(n.Target)
+	escapedHref := html.EscapeString(href)
 	escapedDisplay := html.EscapeString(n.DisplayText)
 
 	// Write the HTML
-	w.WriteString(`<a href="/`)
-	w.WriteString(escapedTarget)
+	w.WriteString(`<a href="`)
+	w.WriteString(escapedHref)
 	w.WriteString(`" class="`)
 	w.WriteString(class)
 	w.WriteString(`">`)
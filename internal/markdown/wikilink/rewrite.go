@@ -0,0 +1,55 @@
+package wikilink
+
+import (
+	"strings"
+
+	"lexicon/internal/database"
+)
+
+// Rewrite points every wiki link in content that targets oldSlug at
+// newTarget instead, keeping any custom display text. It returns the new
+// content and the number of links rewritten. Like the parser, it matches
+// [[...]] within a single line.
+func Rewrite(content, oldSlug, newTarget string) (string, int) {
+	var b strings.Builder
+	count := 0
+	last := 0
+
+	for i := 0; i+1 < len(content); i++ {
+		if content[i] != '[' || content[i+1] != '[' {
+			continue
+		}
+
+		rest := content[i+2:]
+		end := strings.Index(rest, "]]")
+		if end <= 0 || strings.Contains(rest[:end], "\n") {
+			continue
+		}
+
+		inner := rest[:end]
+		target, display, piped := strings.Cut(inner, "|")
+		target = strings.TrimSpace(target)
+		if target == "" || database.Slugify(target) != oldSlug {
+			continue
+		}
+
+		b.WriteString(content[last:i])
+		b.WriteString("[[")
+		b.WriteString(newTarget)
+		if piped {
+			b.WriteString("|")
+			b.WriteString(strings.TrimSpace(display))
+		}
+		b.WriteString("]]")
+
+		count++
+		last = i + 2 + end + 2
+		i = last - 1
+	}
+
+	if count == 0 {
+		return content, 0
+	}
+	b.WriteString(content[last:])
+	return b.String(), count
+}
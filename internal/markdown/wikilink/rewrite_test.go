@@ -0,0 +1,52 @@
+package wikilink
+
+import "testing"
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      string
+		wantCount int
+	}{
+		{
+			name:      "simple link",
+			input:     "See [[Teh Battle]].",
+			want:      "See [[The Battle]].",
+			wantCount: 1,
+		},
+		{
+			name:      "display text kept",
+			input:     "See [[teh battle|the fight]].",
+			want:      "See [[The Battle|the fight]].",
+			wantCount: 1,
+		},
+		{
+			name:      "several links",
+			input:     "[[Teh Battle]] and [[Dragons]] and [[ Teh-Battle ]]",
+			want:      "[[The Battle]] and [[Dragons]] and [[The Battle]]",
+			wantCount: 2,
+		},
+		{
+			name:      "other links untouched",
+			input:     "[[Teh Battles]] [not a link]",
+			want:      "[[Teh Battles]] [not a link]",
+			wantCount: 0,
+		},
+		{
+			name:      "no links across lines",
+			input:     "[[Teh\nBattle]]",
+			want:      "[[Teh\nBattle]]",
+			wantCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := Rewrite(tt.input, "teh-battle", "The Battle")
+			if got != tt.want || count != tt.wantCount {
+				t.Errorf("Rewrite = %q, %d; want %q, %d", got, count, tt.want, tt.wantCount)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -51,3 +52,26 @@ func PublicAccessMiddleware(db *database.DB) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// FeedTokenMiddleware authenticates feed readers by the token query
+// parameter, so private wikis can still be followed from a feed reader.
+func FeedTokenMiddleware(db *database.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.URL.Query().Get("token")
+			if token == "" || GetUser(r) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := db.GetUserByFeedToken(token)
+			if err != nil {
+				http.Error(w, "Invalid feed token", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -44,12 +44,6 @@ func SessionMiddleware(db *database.DB) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Clean up expired sessions periodically (1% of requests)
-			// This is a simple approach; in production you might use a background job
-			if r.URL.Path == "/" {
-				go db.CleanExpiredSessions()
-			}
-
 			// Add user and session to context
 			ctx := context.WithValue(r.Context(), userContextKey, user)
 			ctx = context.WithValue(ctx, sessionContextKey, session)
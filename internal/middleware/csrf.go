@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"net/http"
+	"strings"
 	"sync"
 )
 
@@ -65,11 +66,20 @@ func CSRFMiddleware(store *CSRFStore) func(http.Handler) http.Handler {
 			ctx := context.WithValue(r.Context(), csrfTokenContextKey, token)
 			r = r.WithContext(ctx)
 
-			// Validate token on POST requests
-			if r.Method == http.MethodPost {
-				formToken := r.FormValue("csrf_token")
-				// For POST, we check the submitted token (not the one we just generated)
-				if formToken == "" || !store.Validate(formToken) {
+			// Validate token on requests that change something. Forms send it
+			// as a field, API clients in the X-CSRF-Token header. Requests
+			// with an API token are exempt: browsers never add one by themselves.
+			if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions && GetAPIToken(r) == nil {
+				submitted := r.Header.Get("X-CSRF-Token")
+				if submitted == "" {
+					submitted = r.FormValue("csrf_token")
+				}
+				// We check the submitted token (not the one we just generated)
+				if submitted == "" || !store.Validate(submitted) {
+					if strings.HasPrefix(r.URL.Path, "/api/") {
+						deny(w, r, http.StatusForbidden, "csrf_failed", "Missing or invalid X-CSRF-Token header")
+						return
+					}
 					http.Error(w, "Invalid request", http.StatusForbidden)
 					return
 				}
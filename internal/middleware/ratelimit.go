@@ -16,21 +16,11 @@ type RateLimiter struct {
 
 // NewRateLimiter creates a new rate limiter.
 func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
-	rl := &RateLimiter{
+	return &RateLimiter{
 		requests: make(map[string][]time.Time),
 		limit:    limit,
 		window:   window,
 	}
-
-	// Cleanup old entries periodically
-	go func() {
-		ticker := time.NewTicker(window)
-		for range ticker.C {
-			rl.cleanup()
-		}
-	}()
-
-	return rl
 }
 
 // Allow checks if a request from the given IP is allowed.
@@ -60,7 +50,9 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	return true
 }
 
-func (rl *RateLimiter) cleanup() {
+// Cleanup forgets requests that fell out of the window. Run it periodically to
+// bound memory use.
+func (rl *RateLimiter) Cleanup() {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
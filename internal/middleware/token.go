@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"lexicon/internal/database"
+)
+
+const apiTokenContextKey contextKey = "api_token"
+
+// APITokenMiddleware authenticates requests carrying a personal API token in
+// an "Authorization: Bearer" header. The token's user replaces any session
+// user, limited to what the token's scope allows: only admin tokens keep
+// admin powers, and read tokens can't change anything.
+func APITokenMiddleware(db *database.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scheme, secret, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, err := db.GetAPITokenBySecret(strings.TrimSpace(secret))
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				deny(w, r, http.StatusUnauthorized, "invalid_token", "Invalid or expired API token")
+				return
+			}
+			user, err := db.GetUserByID(token.UserID)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				deny(w, r, http.StatusUnauthorized, "invalid_token", "Invalid or expired API token")
+				return
+			}
+
+			if !token.CanWrite() && r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+				deny(w, r, http.StatusForbidden, "insufficient_scope", "This API token can only read")
+				return
+			}
+			if token.Scope != database.ScopeAdmin && user.IsAdmin() {
+				limited := *user
+				limited.Role = "user"
+				user = &limited
+			}
+
+			if err := db.TouchAPIToken(token.ID); err != nil {
+				log.Printf("Failed to record use of API token %d: %v", token.ID, err)
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			ctx = context.WithValue(ctx, apiTokenContextKey, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetAPIToken returns the API token the request was authenticated with, or
+// nil if it wasn't.
+func GetAPIToken(r *http.Request) *database.APIToken {
+	token, _ := r.Context().Value(apiTokenContextKey).(*database.APIToken)
+	return token
+}
+
+// deny rejects a request, with a JSON error object for API clients and
+// plain text for everyone else.
+func deny(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		http.Error(w, message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{"code": code, "message": message},
+	})
+}
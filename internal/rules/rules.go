@@ -0,0 +1,150 @@
+// Package rules checks entries against the citation rules of a Lexicon game.
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Setting keys for each rule.
+const (
+	SettingNoSelfCitation = "rule_no_self_citation"
+	SettingMinCitations   = "rule_min_citations"
+	SettingMinPhantoms    = "rule_min_phantoms"
+	SettingNoOwnPhantom   = "rule_no_own_phantom"
+)
+
+// Rule names reported in violations.
+const (
+	NoSelfCitation = "no-self-citation"
+	MinCitations   = "min-citations"
+	MinPhantoms    = "min-phantoms"
+	NoOwnPhantom   = "no-own-phantom"
+)
+
+// Config selects which rules are enforced. The zero Config enforces nothing.
+type Config struct {
+	NoSelfCitation bool // entries may not cite their author's other entries
+	MinCitations   int  // entries must cite at least this many written entries
+	MinPhantoms    int  // entries must create at least this many phantoms
+	NoOwnPhantom   bool // players may not write a phantom they created
+}
+
+// ConfigFromSettings reads a Config from the wiki settings. Missing or
+// malformed values disable the rule.
+func ConfigFromSettings(settings map[string]string) Config {
+	atoi := func(key string) int {
+		n, err := strconv.Atoi(settings[key])
+		if err != nil || n < 0 {
+			return 0
+		}
+		return n
+	}
+	return Config{
+		NoSelfCitation: settings[SettingNoSelfCitation] == "true",
+		MinCitations:   atoi(SettingMinCitations),
+		MinPhantoms:    atoi(SettingMinPhantoms),
+		NoOwnPhantom:   settings[SettingNoOwnPhantom] == "true",
+	}
+}
+
+// Enabled reports whether any rule is enforced.
+func (c Config) Enabled() bool {
+	return c != Config{}
+}
+
+// Entry describes an entry being saved.
+type Entry struct {
+	PageID   int64 // 0 for an entry that doesn't exist yet
+	AuthorID int64 // the player the entry belongs to
+	EditorID int64 // the user saving it
+
+	// PhantomCreatorID is the user who first cited the entry, if it is a
+	// phantom being written.
+	PhantomCreatorID int64
+
+	Targets []Target
+}
+
+// Target is an entry cited by the entry being saved.
+type Target struct {
+	Title string
+
+	// Written is true if the target is a written entry, not a phantom or a
+	// missing page.
+	Written bool
+
+	// AuthorID is the author of a written target.
+	AuthorID int64
+
+	// Sealed is true if the target is an entry written in a sealed round
+	// that the editor can't see yet. It counts as the phantom it still
+	// appears to be.
+	Sealed bool
+
+	// FirstCitedInPageID is the page whose citation created a phantom target.
+	FirstCitedInPageID int64
+}
+
+// Violation is a rule an entry breaks.
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// Evaluate returns the rules the entry breaks, in a stable order.
+func Evaluate(cfg Config, e Entry) []Violation {
+	var violations []Violation
+
+	if cfg.NoOwnPhantom && e.PhantomCreatorID != 0 && e.PhantomCreatorID == e.EditorID {
+		violations = append(violations, Violation{
+			Rule:    NoOwnPhantom,
+			Message: "You may not write an entry you first cited yourself.",
+		})
+	}
+
+	var citations, phantoms int
+	var selfCited []string
+	for _, t := range e.Targets {
+		switch {
+		case t.Written && !t.Sealed:
+			citations++
+			if t.AuthorID == e.AuthorID {
+				selfCited = append(selfCited, t.Title)
+			}
+		case t.FirstCitedInPageID == 0 || t.FirstCitedInPageID == e.PageID:
+			// Missing pages become phantoms on save; phantoms this entry
+			// created on an earlier save still count
+			phantoms++
+		}
+	}
+
+	if cfg.NoSelfCitation && len(selfCited) > 0 {
+		violations = append(violations, Violation{
+			Rule:    NoSelfCitation,
+			Message: "Entries may not cite their author's own entries: " + strings.Join(selfCited, ", ") + ".",
+		})
+	}
+	if citations < cfg.MinCitations {
+		violations = append(violations, Violation{
+			Rule:    MinCitations,
+			Message: fmt.Sprintf("Entries must cite at least %d written %s; this one cites %d.", cfg.MinCitations, plural(cfg.MinCitations, "entry", "entries"), citations),
+		})
+	}
+	if phantoms < cfg.MinPhantoms {
+		violations = append(violations, Violation{
+			Rule:    MinPhantoms,
+			Message: fmt.Sprintf("Entries must create at least %d new %s; this one creates %d.", cfg.MinPhantoms, plural(cfg.MinPhantoms, "phantom", "phantoms"), phantoms),
+		})
+	}
+
+	return violations
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
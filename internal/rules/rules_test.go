@@ -0,0 +1,90 @@
+package rules
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	const alice, bob = 1, 2
+	const pageID = 10
+
+	written := func(title string, author int64) Target {
+		return Target{Title: title, Written: true, AuthorID: author}
+	}
+	missing := Target{Title: "New"}
+	ownPhantom := Target{Title: "Mine", FirstCitedInPageID: pageID}
+	otherPhantom := Target{Title: "Theirs", FirstCitedInPageID: 99}
+
+	strict := Config{NoSelfCitation: true, MinCitations: 1, MinPhantoms: 2, NoOwnPhantom: true}
+
+	tests := []struct {
+		name  string
+		cfg   Config
+		entry Entry
+		want  []string
+	}{
+		{
+			name:  "no rules",
+			entry: Entry{AuthorID: alice, EditorID: alice},
+		},
+		{
+			name: "all rules met",
+			cfg:  strict,
+			entry: Entry{PageID: pageID, AuthorID: alice, EditorID: alice,
+				Targets: []Target{written("Dragons", bob), missing, ownPhantom}},
+		},
+		{
+			name: "self citation",
+			cfg:  Config{NoSelfCitation: true},
+			entry: Entry{AuthorID: alice, EditorID: bob,
+				Targets: []Target{written("Dragons", alice)}},
+			want: []string{NoSelfCitation},
+		},
+		{
+			name: "too few citations and phantoms",
+			cfg:  strict,
+			entry: Entry{PageID: pageID, AuthorID: alice, EditorID: alice,
+				Targets: []Target{otherPhantom, missing}},
+			want: []string{MinCitations, MinPhantoms},
+		},
+		{
+			// Written in a sealed round, so it still looks like a phantom
+			// this entry created
+			name: "sealed target",
+			cfg:  Config{NoSelfCitation: true, MinCitations: 1, MinPhantoms: 1},
+			entry: Entry{PageID: pageID, AuthorID: alice, EditorID: bob,
+				Targets: []Target{{Title: "Hidden", Written: true, Sealed: true, AuthorID: alice, FirstCitedInPageID: pageID}}},
+			want: []string{MinCitations},
+		},
+		{
+			name:  "writing own phantom",
+			cfg:   Config{NoOwnPhantom: true},
+			entry: Entry{PageID: pageID, AuthorID: alice, EditorID: alice, PhantomCreatorID: alice},
+			want:  []string{NoOwnPhantom},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Evaluate(tt.cfg, tt.entry)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Evaluate = %v, want rules %v", got, tt.want)
+			}
+			for i, v := range got {
+				if v.Rule != tt.want[i] {
+					t.Errorf("violation %d = %q, want %q", i, v.Rule, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConfigFromSettings(t *testing.T) {
+	cfg := ConfigFromSettings(map[string]string{
+		SettingNoSelfCitation: "true",
+		SettingMinCitations:   "2",
+		SettingMinPhantoms:    "bogus",
+	})
+	want := Config{NoSelfCitation: true, MinCitations: 2}
+	if cfg != want {
+		t.Errorf("ConfigFromSettings = %+v, want %+v", cfg, want)
+	}
+}
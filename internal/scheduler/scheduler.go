@@ -0,0 +1,214 @@
+// Package scheduler runs periodic background jobs inside the server process,
+// persisting each job's latest run in the database so schedules survive
+// restarts.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"lexicon/internal/database"
+)
+
+// ErrUnknownJob is returned by RunNow for a job that isn't registered.
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrJobRunning is returned by RunNow for a job that is already running.
+var ErrJobRunning = errors.New("job already running")
+
+// Job is a unit of periodic work.
+type Job struct {
+	Name        string
+	Description string
+	Interval    time.Duration
+
+	// Run does the work and returns a short summary of what it did.
+	Run func(ctx context.Context) (string, error)
+}
+
+// Status is a registered job together with its latest run.
+type Status struct {
+	*Job
+	LastRun *database.JobRun // nil if the job never ran
+	NextRun time.Time
+}
+
+// Scheduler runs registered jobs when they are due.
+type Scheduler struct {
+	db *database.DB
+
+	// tick is how often due jobs are checked for.
+	tick time.Duration
+
+	mu      sync.Mutex
+	jobs    []*Job
+	running map[string]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a scheduler that records runs in db.
+func New(db *database.DB) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:      db,
+		tick:    15 * time.Second,
+		running: make(map[string]bool),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Register adds a job. Jobs must be registered before Start.
+func (s *Scheduler) Register(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &job)
+}
+
+// Start runs due jobs immediately and then keeps checking in the background
+// until Stop is called.
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.tick)
+		defer ticker.Stop()
+
+		for {
+			s.runDue()
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop cancels running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// RunNow starts a job immediately, regardless of its schedule.
+func (s *Scheduler) RunNow(name string) error {
+	job := s.job(name)
+	if job == nil {
+		return ErrUnknownJob
+	}
+	if !s.launch(job) {
+		return ErrJobRunning
+	}
+	return nil
+}
+
+// Jobs returns the registered jobs with their latest runs, in registration
+// order.
+func (s *Scheduler) Jobs() ([]Status, error) {
+	s.mu.Lock()
+	jobs := append([]*Job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(jobs))
+	for _, job := range jobs {
+		run, err := s.db.GetJobRun(job.Name)
+		if err != nil && err != database.ErrNotFound {
+			return nil, err
+		}
+		status := Status{Job: job, LastRun: run, NextRun: time.Now()}
+		if run != nil {
+			status.NextRun = run.StartedAt.Add(job.Interval)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (s *Scheduler) job(name string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// runDue launches every job whose interval has elapsed since its last start.
+func (s *Scheduler) runDue() {
+	statuses, err := s.Jobs()
+	if err != nil {
+		log.Printf("Scheduler: failed to load job state: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, status := range statuses {
+		if !status.NextRun.After(now) {
+			s.launch(status.Job)
+		}
+	}
+}
+
+// launch runs a job in the background unless it is already running or the
+// scheduler is stopping.
+func (s *Scheduler) launch(job *Job) bool {
+	s.mu.Lock()
+	if s.running[job.Name] || s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return false
+	}
+	s.running[job.Name] = true
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, job.Name)
+			s.mu.Unlock()
+		}()
+		s.run(job)
+	}()
+	return true
+}
+
+func (s *Scheduler) run(job *Job) {
+	if err := s.db.StartJobRun(job.Name, time.Now()); err != nil {
+		log.Printf("Scheduler: failed to record start of %s: %v", job.Name, err)
+	}
+
+	message, err := safeRun(s.ctx, job)
+	status := database.JobOK
+	if err != nil {
+		status = database.JobFailed
+		message = err.Error()
+		log.Printf("Scheduler: job %s failed: %v", job.Name, err)
+	}
+
+	if err := s.db.FinishJobRun(job.Name, status, message); err != nil {
+		log.Printf("Scheduler: failed to record result of %s: %v", job.Name, err)
+	}
+}
+
+// safeRun calls the job, turning a panic into an error so one bad job can't
+// take the server down.
+func safeRun(ctx context.Context, job *Job) (message string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return job.Run(ctx)
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lexicon/internal/database"
+)
+
+func TestScheduler(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "lexicon-test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ran := make(chan string, 10)
+	s := New(db)
+	s.Register(Job{
+		Name:     "due",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (string, error) {
+			ran <- "due"
+			return "did it", nil
+		},
+	})
+	s.Register(Job{
+		Name:     "broken",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) (string, error) {
+			ran <- "broken"
+			return "", errors.New("boom")
+		},
+	})
+
+	// A job that ran recently isn't due again
+	if err := db.StartJobRun("broken", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Start()
+	select {
+	case name := <-ran:
+		if name != "due" {
+			t.Errorf("ran %q, want due", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("due job didn't run")
+	}
+
+	if err := s.RunNow("broken"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunNow("missing"); err != ErrUnknownJob {
+		t.Errorf("RunNow(missing) error = %v, want ErrUnknownJob", err)
+	}
+	s.Stop()
+
+	run, err := db.GetJobRun("due")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != database.JobOK || run.Message != "did it" || run.FinishedAt == nil {
+		t.Errorf("due run = %+v, want finished ok with message", run)
+	}
+
+	run, err = db.GetJobRun("broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != database.JobFailed || run.Message != "boom" {
+		t.Errorf("broken run = %+v, want failed with error message", run)
+	}
+
+	statuses, err := s.Jobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statuses[0].NextRun; !got.After(time.Now().Add(50 * time.Minute)) {
+		t.Errorf("next run of due = %v, want about an hour from now", got)
+	}
+}
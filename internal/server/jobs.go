@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"lexicon/internal/backup"
+	"lexicon/internal/middleware"
+	"lexicon/internal/scheduler"
+	"lexicon/internal/webhook"
+)
+
+// registerJobs adds the server's periodic maintenance jobs to the scheduler.
+func (s *Server) registerJobs(sched *scheduler.Scheduler) {
+	sched.Register(scheduler.Job{
+		Name:        "publish-rounds",
+		Description: "Reveal sealed entries of rounds whose deadline has passed",
+		Interval:    time.Minute,
+		Run: func(ctx context.Context) (string, error) {
+			published, err := s.db.PublishDueRounds()
+			if err != nil {
+				return "", err
+			}
+			// Entries of sealed rounds are announced when they're published
+			if n, err := webhook.EmitPublished(s.db, s.config.BaseURL(), published); err != nil {
+				log.Printf("Webhook: failed to queue %s: %v", webhook.PageCreated, err)
+			} else if n > 0 {
+				sched.RunNow(webhook.JobName)
+			}
+			return fmt.Sprintf("Published %d sealed entries", len(published)), nil
+		},
+	})
+
+	sched.Register(scheduler.Job{
+		Name:        "clean-sessions",
+		Description: "Delete expired login sessions",
+		Interval:    time.Hour,
+		Run: func(ctx context.Context) (string, error) {
+			n, err := s.db.CleanExpiredSessions()
+			return fmt.Sprintf("Removed %d expired sessions", n), err
+		},
+	})
+
+	sched.Register(scheduler.Job{
+		Name:        "clean-claims",
+		Description: "Delete expired claims on phantoms",
+		Interval:    time.Hour,
+		Run: func(ctx context.Context) (string, error) {
+			n, err := s.db.CleanExpiredClaims()
+			return fmt.Sprintf("Removed %d expired claims", n), err
+		},
+	})
+
+	deliverer := webhook.NewDeliverer(s.db)
+	sched.Register(scheduler.Job{
+		Name:        webhook.JobName,
+		Description: "Send queued webhook deliveries and retry failed ones",
+		Interval:    time.Minute,
+		Run: func(ctx context.Context) (string, error) {
+			delivered, failed, err := deliverer.DeliverDue(ctx)
+			return fmt.Sprintf("Delivered %d, failed %d", delivered, failed), err
+		},
+	})
+
+	sched.Register(scheduler.Job{
+		Name:        "clean-webhook-deliveries",
+		Description: "Delete finished webhook deliveries older than 30 days",
+		Interval:    24 * time.Hour,
+		Run: func(ctx context.Context) (string, error) {
+			n, err := s.db.CleanWebhookDeliveries(time.Now().Add(-webhook.Retention))
+			return fmt.Sprintf("Removed %d old deliveries", n), err
+		},
+	})
+
+	sched.Register(scheduler.Job{
+		Name:        "clean-rate-limits",
+		Description: "Forget login and registration attempts outside the rate limit window",
+		Interval:    10 * time.Minute,
+		Run: func(ctx context.Context) (string, error) {
+			middleware.LoginLimiter.Cleanup()
+			middleware.RegisterLimiter.Cleanup()
+			return "Rate limiters cleaned", nil
+		},
+	})
+
+	sched.Register(scheduler.Job{
+		Name:        "optimize-indexes",
+		Description: "Merge search index segments and refresh query planner statistics",
+		Interval:    24 * time.Hour,
+		Run: func(ctx context.Context) (string, error) {
+			if err := s.db.OptimizeIndexes(); err != nil {
+				return "", err
+			}
+			return "Indexes optimized", nil
+		},
+	})
+
+	sched.Register(scheduler.Job{
+		Name:        "backup",
+		Description: "Snapshot the database when the configured backup interval has passed",
+		Interval:    time.Hour,
+		Run: func(ctx context.Context) (string, error) {
+			interval, err := s.db.BackupInterval()
+			if err != nil {
+				return "", err
+			}
+			if interval == 0 {
+				return "Scheduled backups are off", nil
+			}
+
+			files, err := backup.List(s.config.BackupDir())
+			if err != nil {
+				return "", err
+			}
+			// Allow a little slack so an hourly check doesn't skip a beat
+			if len(files) > 0 && time.Since(files[0].CreatedAt) < interval-time.Minute {
+				return "Latest backup is recent enough", nil
+			}
+
+			keep, err := s.db.BackupKeep()
+			if err != nil {
+				return "", err
+			}
+			f, err := backup.Create(s.db, s.config.BackupDir(), keep)
+			if err != nil {
+				return "", err
+			}
+			return "Created " + f.Name, nil
+		},
+	})
+}
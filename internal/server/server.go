@@ -15,7 +15,9 @@ import (
 	"lexicon/internal/config"
 	"lexicon/internal/database"
 	"lexicon/internal/handler"
+	"lexicon/internal/markdown"
 	"lexicon/internal/middleware"
+	"lexicon/internal/scheduler"
 
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
@@ -28,6 +30,7 @@ type Server struct {
 	db         *database.DB
 	handler    *handler.Handler
 	router     *chi.Mux
+	scheduler  *scheduler.Scheduler
 	embeddedFS fs.FS
 }
 
@@ -43,8 +46,7 @@ func New(cfg *config.Config, db *database.DB, embeddedFS fs.FS) *Server {
 // Run starts the HTTP server.
 func (s *Server) Run() error {
 	// Create overlay filesystem for templates
-	tmplFS := newOverlayFS("templates", mustSubFS(s.embeddedFS, "templates"))
-	staticFS := newOverlayFS("static", mustSubFS(s.embeddedFS, "static"))
+	tmplFS, staticFS := Assets(s.embeddedFS)
 
 	// Create handler
 	var err error
@@ -53,6 +55,30 @@ func (s *Server) Run() error {
 		return err
 	}
 
+	// Build the link graph for databases created before links were tracked
+	if needsRebuild, err := s.db.NeedsLinkRebuild(); err != nil {
+		log.Printf("Failed to check link graph: %v", err)
+	} else if needsRebuild {
+		log.Println("Building link graph from existing revisions...")
+		err := s.db.RebuildLinks(func(content string) []database.Link {
+			return markdown.DatabaseLinks(s.handler.Markdown.ExtractLinks(content))
+		})
+		if err != nil {
+			log.Printf("Failed to build link graph: %v", err)
+		} else if retired, err := s.db.RetireOrphanedPhantoms(); err != nil {
+			log.Printf("Failed to retire orphaned phantoms: %v", err)
+		} else if retired > 0 {
+			log.Printf("Retired %d phantoms that are no longer cited", retired)
+		}
+	}
+
+	// Start background jobs
+	s.scheduler = scheduler.New(s.db)
+	s.registerJobs(s.scheduler)
+	s.scheduler.Start()
+	s.handler.Scheduler = s.scheduler
+	s.handler.StaticFS = staticFS
+
 	// Set up router
 	s.router = chi.NewRouter()
 
@@ -61,6 +87,7 @@ func (s *Server) Run() error {
 	s.router.Use(chimw.Logger)
 	s.router.Use(chimw.Recoverer)
 	s.router.Use(middleware.SessionMiddleware(s.db))
+	s.router.Use(middleware.APITokenMiddleware(s.db))
 	s.router.Use(middleware.CSRFMiddleware(s.handler.CSRFStore))
 
 	// Static files
@@ -73,6 +100,36 @@ func (s *Server) Run() error {
 	s.router.Get("/register", s.handler.RegisterForm)
 	s.router.With(middleware.RateLimitMiddleware(middleware.RegisterLimiter)).Post("/register", s.handler.Register)
 
+	// Feeds (feed readers may authenticate with a per-user token)
+	s.router.Group(func(r chi.Router) {
+		r.Use(middleware.FeedTokenMiddleware(s.db))
+		r.Use(middleware.PublicAccessMiddleware(s.db))
+
+		r.Get("/feeds/changes.{format:atom|rss}", s.handler.ChangesFeed)
+		r.Get("/feeds/pages.{format:atom|rss}", s.handler.NewPagesFeed)
+		r.Get("/feeds/phantoms.{format:atom|rss}", s.handler.PhantomsFeed)
+		r.Get("/{slug}/comments.{format:atom|rss}", s.handler.CommentsFeed)
+	})
+
+	// JSON API (reading follows public_read_access, writing needs a login)
+	s.router.Route("/api/v1", func(r chi.Router) {
+		r.Use(s.handler.APIAccess)
+		r.NotFound(s.handler.APINotFound)
+		r.MethodNotAllowed(s.handler.APIMethodNotAllowed)
+
+		r.Get("/pages", s.handler.APIListPages)
+		r.Post("/pages", s.handler.APICreatePage)
+		r.Get("/pages/{slug}", s.handler.APIGetPage)
+		r.Put("/pages/{slug}", s.handler.APIUpdatePage)
+		r.Get("/pages/{slug}/html", s.handler.APIPageHTML)
+		r.Get("/pages/{slug}/revisions", s.handler.APIListRevisions)
+		r.Get("/pages/{slug}/revisions/{revisionID}", s.handler.APIGetRevision)
+		r.Get("/pages/{slug}/comments", s.handler.APIListComments)
+		r.Post("/pages/{slug}/comments", s.handler.APIAddComment)
+		r.Get("/phantoms", s.handler.APIListPhantoms)
+		r.Get("/search", s.handler.APISearch)
+	})
+
 	// Public routes (access controlled by PublicAccessMiddleware)
 	s.router.Group(func(r chi.Router) {
 		r.Use(middleware.PublicAccessMiddleware(s.db))
@@ -81,11 +138,14 @@ func (s *Server) Run() error {
 		r.Get("/pages", s.handler.ListPages)
 		r.Get("/pages/phantoms", s.handler.ListPhantoms)
 		r.Get("/pages/recent", s.handler.RecentPages)
+		r.Get("/special/recent-changes", s.handler.RecentChanges)
 		r.Get("/search", s.handler.Search)
 
 		// Page routes at root level (must be after specific routes)
 		r.Get("/{slug}", s.handler.ViewPage)
 		r.Get("/{slug}/history", s.handler.PageHistory)
+		r.Get("/{slug}/backlinks", s.handler.PageBacklinks)
+		r.Get("/{slug}/diff", s.handler.PageDiff)
 		r.Get("/{slug}/revision/{revisionID}", s.handler.ViewRevision)
 	})
 
@@ -93,11 +153,21 @@ func (s *Server) Run() error {
 	s.router.Group(func(r chi.Router) {
 		r.Use(middleware.RequireAuth)
 
+		r.Get("/account", s.handler.Account)
+		r.Post("/account/persona", s.handler.SavePersona)
 		r.Get("/account/password", s.handler.ChangePasswordForm)
 		r.Post("/account/password", s.handler.ChangePassword)
+		r.Get("/account/feeds", s.handler.AccountFeeds)
+		r.Post("/account/feeds/reset", s.handler.ResetFeedToken)
+		r.Get("/account/tokens", s.handler.AccountTokens)
+		r.Post("/account/tokens", s.handler.CreateAPIToken)
+		r.Post("/account/tokens/{id}/delete", s.handler.DeleteAPIToken)
 		r.Get("/{slug}/edit", s.handler.EditPage)
 		r.Post("/{slug}", s.handler.SavePage)
 		r.Post("/{slug}/comments", s.handler.AddComment)
+		r.Post("/{slug}/claim", s.handler.ClaimPage)
+		r.Post("/{slug}/release", s.handler.ReleaseClaim)
+		r.Post("/{slug}/revision/{revisionID}/revert", s.handler.RevertRevision)
 	})
 
 	// Admin routes
@@ -111,9 +181,40 @@ func (s *Server) Run() error {
 		r.Post("/admin/users/{userID}/role", s.handler.AdminChangeRole)
 		r.Post("/admin/users/{userID}/delete", s.handler.AdminDeleteUser)
 		r.Get("/admin/export", s.handler.Export)
+		r.Get("/admin/export/history", s.handler.ExportHistory)
+		r.Get("/admin/export/site", s.handler.ExportSite)
+		r.Get("/admin/export/book", s.handler.ExportBook)
+		r.Get("/admin/book", s.handler.AdminBook)
+		r.Get("/admin/import", s.handler.AdminImportForm)
+		r.Post("/admin/import", s.handler.AdminImport)
 		r.Get("/admin/deleted", s.handler.AdminDeletedPages)
 		r.Post("/admin/deleted/{pageID}/restore", s.handler.AdminRestorePage)
+		r.Get("/admin/game", s.handler.AdminGame)
+		r.Post("/admin/game/rounds", s.handler.AdminCreateRound)
+		r.Post("/admin/game/rounds/alphabet", s.handler.AdminCreateAlphabetRounds)
+		r.Get("/admin/game/rounds/{roundID}", s.handler.AdminRound)
+		r.Post("/admin/game/rounds/{roundID}", s.handler.AdminUpdateRound)
+		r.Post("/admin/game/rounds/{roundID}/open", s.handler.AdminOpenRound)
+		r.Post("/admin/game/rounds/{roundID}/close", s.handler.AdminCloseRound)
+		r.Post("/admin/game/rounds/{roundID}/publish", s.handler.AdminPublishRound)
+		r.Post("/admin/game/rounds/{roundID}/delete", s.handler.AdminDeleteRound)
+		r.Get("/admin/assignments", s.handler.AdminAssignments)
+		r.Post("/admin/assignments", s.handler.AdminAssign)
+		r.Get("/admin/backups", s.handler.AdminBackups)
+		r.Post("/admin/backups", s.handler.AdminCreateBackup)
+		r.Get("/admin/backups/{name}", s.handler.AdminDownloadBackup)
+		r.Get("/admin/jobs", s.handler.AdminJobs)
+		r.Post("/admin/jobs/{name}/run", s.handler.AdminRunJob)
+		r.Get("/admin/webhooks", s.handler.AdminWebhooks)
+		r.Post("/admin/webhooks", s.handler.AdminCreateWebhook)
+		r.Get("/admin/webhooks/{webhookID}", s.handler.AdminWebhook)
+		r.Post("/admin/webhooks/{webhookID}", s.handler.AdminUpdateWebhook)
+		r.Post("/admin/webhooks/{webhookID}/delete", s.handler.AdminDeleteWebhook)
+		r.Post("/admin/webhooks/{webhookID}/test", s.handler.AdminTestWebhook)
+		r.Post("/admin/webhooks/deliveries/{deliveryID}/retry", s.handler.AdminRetryDelivery)
 		r.Post("/{slug}/delete", s.handler.DeletePage)
+		r.Get("/{slug}/move", s.handler.MovePageForm)
+		r.Post("/{slug}/move", s.handler.MovePage)
 	})
 
 	// Create HTTP server
@@ -141,6 +242,7 @@ func (s *Server) Run() error {
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Fatalf("Could not gracefully shutdown: %v", err)
 		}
+		s.scheduler.Stop()
 		close(done)
 	}()
 
@@ -191,6 +293,13 @@ func (s *Server) runHTTPS(srv *http.Server) error {
 	return nil
 }
 
+// Assets returns the templates and static files, preferring customized
+// copies in ./templates and ./static over the embedded ones.
+func Assets(embeddedFS fs.FS) (templates, static fs.FS) {
+	return newOverlayFS("templates", mustSubFS(embeddedFS, "templates")),
+		newOverlayFS("static", mustSubFS(embeddedFS, "static"))
+}
+
 // overlayFS checks local disk first, then falls back to embedded.
 type overlayFS struct {
 	disk     string
@@ -0,0 +1,311 @@
+// Package site renders the wiki as a static website: one HTML file per page,
+// an index, the phantom list and a search page that works without a server.
+package site
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"lexicon/internal/database"
+	"lexicon/internal/markdown"
+	"lexicon/internal/markdown/wikilink"
+)
+
+// Generator builds the static site from the database, using the site
+// templates in Templates and copying Static to static/.
+type Generator struct {
+	DB        *database.DB
+	Templates fs.FS
+	Static    fs.FS
+}
+
+// WriteZip writes the site as a ZIP archive.
+func (g *Generator) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	err := g.generate(func(name string, data []byte) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// WriteDir writes the site into dir, creating it if needed. Existing files
+// with the same names are overwritten.
+func (g *Generator) WriteDir(dir string) error {
+	return g.generate(func(name string, data []byte) error {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(p, data, 0644)
+	})
+}
+
+// searchEntry is one page in search-index.js.
+type searchEntry struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// templateData is passed to every site template. Root is the relative path
+// from the file being rendered back to the site root.
+type templateData struct {
+	WikiTitle   string
+	Title       string
+	Root        string
+	GeneratedAt time.Time
+	Data        map[string]any
+}
+
+type generator struct {
+	*Generator
+	write     func(name string, data []byte) error
+	wikiTitle string
+	now       time.Time
+	markdown  *markdown.Renderer
+	templates map[string]*template.Template
+
+	// pages maps the slug of every exported page to its title
+	pages map[string]string
+	// redirects caches where aliases and former slugs point, "" if nowhere
+	redirects map[string]string
+}
+
+func (g *Generator) generate(write func(name string, data []byte) error) error {
+	wikiTitle, err := g.DB.WikiTitle()
+	if err != nil {
+		return err
+	}
+
+	gen := &generator{
+		Generator: g,
+		write:     write,
+		wikiTitle: wikiTitle,
+		now:       time.Now(),
+		markdown:  markdown.New(nil),
+		templates: make(map[string]*template.Template),
+		pages:     make(map[string]string),
+		redirects: make(map[string]string),
+	}
+	if err := gen.loadTemplates(); err != nil {
+		return err
+	}
+	return gen.run()
+}
+
+func (g *generator) loadTemplates() error {
+	funcMap := template.FuncMap{
+		"safe": func(s string) template.HTML {
+			return template.HTML(s)
+		},
+	}
+
+	layout, err := fs.ReadFile(g.Templates, "site/layout.html")
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"page.html", "index.html", "phantoms.html", "search.html"} {
+		content, err := fs.ReadFile(g.Templates, "site/"+name)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New("layout").Funcs(funcMap).Parse(string(layout))
+		if err != nil {
+			return err
+		}
+		if tmpl, err = tmpl.Parse(string(content)); err != nil {
+			return fmt.Errorf("site/%s: %w", name, err)
+		}
+		g.templates[name] = tmpl
+	}
+	return nil
+}
+
+func (g *generator) run() error {
+	pages, err := g.DB.ListPages()
+	if err != nil {
+		return err
+	}
+	phantoms, err := g.DB.ListPhantomsWithSource()
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		g.pages[page.Slug] = page.Title
+	}
+
+	var index []searchEntry
+	for _, page := range pages {
+		rev, err := g.DB.GetCurrentRevision(page.ID)
+		if err != nil {
+			return fmt.Errorf("page %s: %w", page.Slug, err)
+		}
+		content, err := g.render(rev.Content, "../")
+		if err != nil {
+			return fmt.Errorf("page %s: %w", page.Slug, err)
+		}
+		backlinks, err := g.DB.ListBacklinks(page.Slug)
+		if err != nil {
+			return err
+		}
+
+		err = g.page("pages/"+page.Slug+".html", "page.html", page.Title, "../", map[string]any{
+			"Page":        page,
+			"Revision":    rev,
+			"Content":     content,
+			"Backlinks":   backlinks,
+			"RedirectURL": g.linkURL("../")(page.RedirectTo),
+		})
+		if err != nil {
+			return err
+		}
+
+		index = append(index, searchEntry{Slug: page.Slug, Title: page.Title, Text: plainText(content)})
+	}
+
+	// The home page, if written, introduces the index like it does in the wiki
+	var homeContent string
+	if _, ok := g.pages["home-page"]; ok {
+		home, err := g.DB.GetPageBySlug("home-page")
+		if err != nil {
+			return err
+		}
+		rev, err := g.DB.GetCurrentRevision(home.ID)
+		if err != nil {
+			return err
+		}
+		if homeContent, err = g.render(rev.Content, ""); err != nil {
+			return err
+		}
+	}
+
+	if err := g.page("index.html", "index.html", "Home", "", map[string]any{
+		"Pages":       pages,
+		"Phantoms":    phantoms,
+		"HomeContent": homeContent,
+	}); err != nil {
+		return err
+	}
+	if err := g.page("phantoms.html", "phantoms.html", "Phantom Pages", "", map[string]any{
+		"Phantoms": phantoms,
+	}); err != nil {
+		return err
+	}
+	if err := g.page("search.html", "search.html", "Search", "", map[string]any{}); err != nil {
+		return err
+	}
+
+	if index == nil {
+		index = []searchEntry{}
+	}
+	js, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := g.write("search-index.js", []byte("var SEARCH_INDEX = "+string(js)+";\n")); err != nil {
+		return err
+	}
+
+	return g.copyStatic()
+}
+
+// page renders a site template to the named file.
+func (g *generator) page(name, tmpl, title, root string, data map[string]any) error {
+	var buf bytes.Buffer
+	err := g.templates[tmpl].ExecuteTemplate(&buf, "layout", templateData{
+		WikiTitle:   g.wikiTitle,
+		Title:       title,
+		Root:        root,
+		GeneratedAt: g.now,
+		Data:        data,
+	})
+	if err != nil {
+		return fmt.Errorf("rendering %s: %w", name, err)
+	}
+	return g.write(name, buf.Bytes())
+}
+
+// render converts page content to HTML for a file at root, pointing wiki
+// links at the exported pages and marking everything else as a phantom.
+func (g *generator) render(content, root string) (string, error) {
+	return g.markdown.RenderLinked(content, g.pageStatus, g.linkURL(root))
+}
+
+func (g *generator) pageStatus(slug string) (exists bool, isPhantom bool) {
+	target := g.resolve(slug)
+	return target != "", target == ""
+}
+
+// linkURL returns where links in a file at root should point: the exported
+// page, or its entry in the phantom list if it isn't part of the site.
+func (g *generator) linkURL(root string) wikilink.LinkURL {
+	return func(slug string) string {
+		if target := g.resolve(slug); target != "" {
+			return root + "pages/" + target + ".html"
+		}
+		return root + "phantoms.html#" + slug
+	}
+}
+
+// resolve returns the slug of the exported page a link to slug leads to,
+// following aliases and former slugs, or "" if there is none.
+func (g *generator) resolve(slug string) string {
+	if _, ok := g.pages[slug]; ok {
+		return slug
+	}
+	target, ok := g.redirects[slug]
+	if !ok {
+		if page, err := g.DB.ResolveRedirect(slug); err == nil {
+			if _, exported := g.pages[page.Slug]; exported {
+				target = page.Slug
+			}
+		}
+		g.redirects[slug] = target
+	}
+	return target
+}
+
+func (g *generator) copyStatic() error {
+	return fs.WalkDir(g.Static, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(g.Static, p)
+		if err != nil {
+			return err
+		}
+		return g.write(path.Join("static", p), data)
+	})
+}
+
+var (
+	tagRegex   = regexp.MustCompile(`<[^>]*>`)
+	spaceRegex = regexp.MustCompile(`\s+`)
+)
+
+// plainText strips rendered HTML down to the words a reader would search for.
+// Blocks are already separated by newlines in the rendered HTML.
+func plainText(s string) string {
+	s = html.UnescapeString(tagRegex.ReplaceAllString(s, ""))
+	return strings.TrimSpace(spaceRegex.ReplaceAllString(s, " "))
+}
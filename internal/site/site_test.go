@@ -0,0 +1,76 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lexicon/internal/database"
+)
+
+func TestWriteDir(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dwarves, err := db.CreatePage("dwarves", "Dwarves", "Miners of the deep.", alice.ID, nil, database.RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetAliases(dwarves.ID, []string{"Dwarf"}); err != nil {
+		t.Fatal(err)
+	}
+	content := "Elves distrust [[Dwarf|dwarves]] and fear [[Orcs]]."
+	links := []database.Link{{TargetSlug: "dwarf", DisplayText: "dwarves"}, {TargetSlug: "orcs", DisplayText: "Orcs"}}
+	elves, err := db.CreatePage("elves", "Elves", content, alice.ID, links, database.RevisionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePhantom("orcs", "Orcs", alice.ID, elves.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	gen := &Generator{DB: db, Templates: os.DirFS("../../templates"), Static: os.DirFS("../../static")}
+	if err := gen.WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	page := read("pages/elves.html")
+	for _, want := range []string{
+		`href="../pages/dwarves.html" class="wiki-link"`,
+		`href="../phantoms.html#orcs" class="wiki-link phantom"`,
+		`href="../static/bulma.min.css"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("elves.html lacks %s", want)
+		}
+	}
+	if backlinks := read("pages/dwarves.html"); !strings.Contains(backlinks, `href="elves.html"`) {
+		t.Error("dwarves.html lacks a backlink to elves")
+	}
+	if phantoms := read("phantoms.html"); !strings.Contains(phantoms, `id="orcs"`) {
+		t.Error("phantoms.html lacks an anchor for orcs")
+	}
+	if index := read("search-index.js"); !strings.Contains(index, `"text":"Elves distrust dwarves and fear Orcs."`) {
+		t.Errorf("search index = %s", index)
+	}
+	read("index.html")
+	read("static/lexicon.css")
+}
@@ -0,0 +1,330 @@
+// Package webhook notifies external services of wiki events. Events are
+// queued in the database as signed JSON payloads and delivered in the
+// background, with retries and backoff for endpoints that are down.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lexicon/internal/database"
+)
+
+// Event names, as sent in payloads and the X-Lexicon-Event header.
+const (
+	PageCreated    = "page.created"
+	PageUpdated    = "page.updated"
+	PageDeleted    = "page.deleted"
+	PageRestored   = "page.restored"
+	PhantomCreated = "phantom.created"
+	CommentAdded   = "comment.added"
+	UserRegistered = "user.registered"
+
+	// Ping is sent by the test button, whatever a webhook subscribes to.
+	Ping = "ping"
+)
+
+// EventInfo describes an event webhooks can subscribe to.
+type EventInfo struct {
+	Name        string
+	Description string
+}
+
+// Events lists the events webhooks can subscribe to.
+var Events = []EventInfo{
+	{PageCreated, "An entry is written, including phantoms being filled in"},
+	{PageUpdated, "An entry is edited or reverted"},
+	{PhantomCreated, "An entry cites a page that doesn't exist yet"},
+	{CommentAdded, "Someone comments on an entry"},
+	{PageDeleted, "An admin deletes a page"},
+	{PageRestored, "An admin restores a deleted page"},
+	{UserRegistered, "Someone signs up"},
+}
+
+// ValidEvent reports whether webhooks can subscribe to the named event.
+func ValidEvent(name string) bool {
+	for _, e := range Events {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// JobName is the scheduler job that sends queued deliveries.
+const JobName = "deliver-webhooks"
+
+// MaxAttempts is how many times a delivery is tried before giving up.
+const MaxAttempts = 6
+
+// backoff is the wait after each failed attempt.
+var backoff = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	12 * time.Hour,
+}
+
+// MinSecretLength is the shortest secret a webhook may be given.
+const MinSecretLength = 16
+
+// Retention is how long finished deliveries stay in the delivery log.
+const Retention = 30 * 24 * time.Hour
+
+// maxResponse is how much of a response body is kept in the delivery log.
+const maxResponse = 1024
+
+// Payload is the JSON body of every delivery.
+type Payload struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Wiki      string    `json:"wiki"`
+	// Text summarizes the event in one line, which chat services that
+	// accept incoming webhooks show as the message
+	Text string `json:"text"`
+	Data any    `json:"data"`
+}
+
+// Page identifies a page in payload data.
+type Page struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// User identifies a user in payload data.
+type User struct {
+	Username string `json:"username"`
+}
+
+// Revision describes the revision a page event created.
+type Revision struct {
+	ID      int64  `json:"id"`
+	Summary string `json:"summary,omitempty"`
+	Minor   bool   `json:"minor"`
+}
+
+// PageData is the data of page events.
+type PageData struct {
+	Page     Page      `json:"page"`
+	User     *User     `json:"user,omitempty"`
+	Revision *Revision `json:"revision,omitempty"`
+}
+
+// PhantomData is the data of phantom.created.
+type PhantomData struct {
+	Phantom Page `json:"phantom"`
+	CitedIn Page `json:"cited_in"`
+	User    User `json:"user"`
+}
+
+// Comment is a comment in payload data.
+type Comment struct {
+	ID      int64  `json:"id"`
+	Content string `json:"content"`
+}
+
+// CommentData is the data of comment.added.
+type CommentData struct {
+	Page    Page    `json:"page"`
+	Comment Comment `json:"comment"`
+	User    User    `json:"user"`
+}
+
+// UserData is the data of user.registered.
+type UserData struct {
+	User User `json:"user"`
+}
+
+// Emit queues an event for every webhook subscribed to it and returns how
+// many deliveries were queued.
+func Emit(db *database.DB, event, text string, data any) (int, error) {
+	body, err := payload(db, event, text, data)
+	if err != nil {
+		return 0, err
+	}
+	return db.QueueWebhookDeliveries(event, body)
+}
+
+// NewPage identifies a page in payload data, linking to it under baseURL.
+func NewPage(baseURL string, page *database.Page) Page {
+	return Page{Slug: page.Slug, Title: page.Title, URL: baseURL + "/" + page.Slug}
+}
+
+// EmitPageSaved queues a page.created or page.updated event for a page's
+// current revision, saved by the named user.
+func EmitPageSaved(db *database.DB, baseURL, event string, page *database.Page, username string) (int, error) {
+	data := PageData{Page: NewPage(baseURL, page), User: &User{Username: username}}
+	if rev, err := db.GetCurrentRevision(page.ID); err == nil {
+		data.Revision = &Revision{ID: rev.ID, Summary: rev.Summary, Minor: rev.IsMinor}
+	}
+
+	verb := "edited"
+	if event == PageCreated {
+		verb = "wrote"
+	}
+	text := fmt.Sprintf("%s %s %s: %s", username, verb, page.Title, data.Page.URL)
+	return Emit(db, event, text, data)
+}
+
+// EmitPublished queues page.created for entries of a sealed round that were
+// just published. They weren't announced when they were written.
+func EmitPublished(db *database.DB, baseURL string, pageIDs []int64) (int, error) {
+	queued := 0
+	for _, id := range pageIDs {
+		page, err := db.GetPageByID(id)
+		if err != nil {
+			return queued, err
+		}
+		authorID, err := db.PageAuthor(id)
+		if err != nil {
+			return queued, err
+		}
+		author, err := db.GetUserByID(authorID)
+		if err != nil {
+			return queued, err
+		}
+		n, err := EmitPageSaved(db, baseURL, PageCreated, page, author.Username)
+		if err != nil {
+			return queued, err
+		}
+		queued += n
+	}
+	return queued, nil
+}
+
+// SendPing queues a ping to one webhook, to check that it's set up right.
+func SendPing(db *database.DB, hook *database.Webhook) error {
+	body, err := payload(db, Ping, "Webhook test from Lexicon", map[string]int64{"webhook_id": hook.ID})
+	if err != nil {
+		return err
+	}
+	return db.QueueWebhookDelivery(hook.ID, Ping, body)
+}
+
+func payload(db *database.DB, event, text string, data any) ([]byte, error) {
+	wiki, err := db.WikiTitle()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Payload{
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Wiki:      wiki,
+		Text:      text,
+		Data:      data,
+	})
+}
+
+// NewSecret returns a random secret for signing deliveries.
+func NewSecret() (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// Sign returns the X-Lexicon-Signature of a body: "sha256=" and the hex
+// HMAC-SHA256 of the body, keyed with the webhook's secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliverer sends queued deliveries.
+type Deliverer struct {
+	DB     *database.DB
+	Client *http.Client
+}
+
+// NewDeliverer creates a deliverer with a client that gives up on slow
+// endpoints.
+func NewDeliverer(db *database.DB) *Deliverer {
+	return &Deliverer{DB: db, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// DeliverDue sends every delivery that is due, including any queued while it
+// runs, and returns how many were delivered and how many failed.
+func (d *Deliverer) DeliverDue(ctx context.Context) (delivered, failed int, err error) {
+	for ctx.Err() == nil {
+		due, err := d.DB.DueWebhookDeliveries(50)
+		if err != nil {
+			return delivered, failed, err
+		}
+		if len(due) == 0 {
+			break
+		}
+		for _, delivery := range due {
+			if ctx.Err() != nil {
+				break
+			}
+			ok, err := d.deliver(ctx, delivery)
+			if err != nil {
+				return delivered, failed, err
+			}
+			if ok {
+				delivered++
+			} else {
+				failed++
+			}
+		}
+	}
+	return delivered, failed, nil
+}
+
+// deliver makes one attempt at a delivery and records how it went.
+func (d *Deliverer) deliver(ctx context.Context, delivery *database.WebhookDelivery) (bool, error) {
+	body := []byte(delivery.Payload)
+	status, response, ok := d.post(ctx, delivery, body)
+
+	var next *time.Time
+	if !ok && delivery.Attempts+1 < MaxAttempts {
+		t := time.Now().Add(backoff[min(delivery.Attempts, len(backoff)-1)])
+		next = &t
+	}
+	if err := d.DB.RecordWebhookAttempt(delivery.ID, ok, status, response, next); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// post sends a delivery, reporting the response status and the start of
+// the response body, or the error if there was no response.
+func (d *Deliverer) post(ctx context.Context, delivery *database.WebhookDelivery, body []byte) (int, string, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error(), false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Lexicon-Webhook")
+	req.Header.Set("X-Lexicon-Event", delivery.Event)
+	req.Header.Set("X-Lexicon-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Lexicon-Signature", Sign(delivery.Secret, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err.Error(), false
+	}
+	defer resp.Body.Close()
+
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	ok := resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !ok && len(snippet) == 0 {
+		snippet = []byte(fmt.Sprintf("HTTP %d", resp.StatusCode))
+	}
+	return resp.StatusCode, string(snippet), ok
+}
@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"lexicon/internal/database"
+)
+
+func openTestDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestDeliver(t *testing.T) {
+	db := openTestDB(t)
+
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	var mu sync.Mutex
+	var received []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, request{r.Header, body})
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	hook, err := db.CreateWebhook(srv.URL, "s3cret", []string{PageCreated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateWebhook(srv.URL, "other", []string{CommentAdded}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := PageData{Page: Page{Slug: "elves", Title: "Elves", URL: "http://wiki/elves"}}
+	if n, err := Emit(db, PageCreated, "New entry: Elves", data); err != nil || n != 1 {
+		t.Fatalf("Emit = %d, %v; want 1 delivery for the subscribed webhook", n, err)
+	}
+
+	delivered, failed, err := NewDeliverer(db).DeliverDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 1 || failed != 0 || len(received) != 1 {
+		t.Fatalf("delivered %d, failed %d, received %d; want 1, 0, 1", delivered, failed, len(received))
+	}
+
+	req := received[0]
+	if got := req.header.Get("X-Lexicon-Signature"); got != Sign(hook.Secret, req.body) {
+		t.Errorf("signature = %q, want %q", got, Sign(hook.Secret, req.body))
+	}
+	if got := req.header.Get("X-Lexicon-Event"); got != PageCreated {
+		t.Errorf("X-Lexicon-Event = %q", got)
+	}
+	var p struct {
+		Event string
+		Text  string
+		Data  PageData
+	}
+	if err := json.Unmarshal(req.body, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Event != PageCreated || p.Text != "New entry: Elves" || p.Data.Page.Slug != "elves" {
+		t.Errorf("payload = %s", req.body)
+	}
+
+	deliveries, err := db.ListWebhookDeliveries(hook.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != database.DeliveryDelivered || deliveries[0].ResponseStatus != 200 {
+		t.Errorf("deliveries = %+v", deliveries)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	db := openTestDB(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	hook, err := db.CreateWebhook(srv.URL, "s3cret", []string{UserRegistered})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Emit(db, UserRegistered, "New user: bob", UserData{User{"bob"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDeliverer(db)
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		if _, failed, err := d.DeliverDue(context.Background()); err != nil || failed != 1 {
+			t.Fatalf("attempt %d: failed = %d, err = %v", attempt, failed, err)
+		}
+
+		deliveries, err := db.ListWebhookDeliveries(hook.ID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dl := deliveries[0]
+		if dl.Attempts != attempt || dl.ResponseStatus != http.StatusServiceUnavailable {
+			t.Fatalf("attempt %d: delivery = %+v", attempt, dl)
+		}
+		if attempt < MaxAttempts {
+			if dl.Status != database.DeliveryPending || dl.NextAttemptAt == nil || !dl.NextAttemptAt.After(time.Now()) {
+				t.Fatalf("attempt %d: want a retry later, got %+v", attempt, dl)
+			}
+			// Skip the backoff
+			if _, err := db.Exec("UPDATE webhook_deliveries SET next_attempt_at = ?", time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		} else if dl.Status != database.DeliveryFailed {
+			t.Errorf("after %d attempts, status = %s, want failed", attempt, dl.Status)
+		}
+	}
+
+	if delivered, failed, _ := d.DeliverDue(context.Background()); delivered+failed != 0 {
+		t.Error("a failed delivery was retried")
+	}
+}
+
+func TestEmitPublished(t *testing.T) {
+	db := openTestDB(t)
+
+	alice, err := db.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSetting("seal_round_entries", "true"); err != nil {
+		t.Fatal(err)
+	}
+	round, err := db.CreateRound("A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.OpenRound(round.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreatePage("aardvarks", "Aardvarks", "Burrowing mammals.", alice.ID, nil, database.RevisionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	hook, err := db.CreateWebhook("http://example.invalid/hook", "s3cret", []string{PageCreated})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	published, err := db.PublishRound(round.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := EmitPublished(db, "https://wiki.example.com", published); err != nil || n != 1 {
+		t.Fatalf("EmitPublished = %d, %v; want 1 delivery", n, err)
+	}
+
+	deliveries, err := db.ListWebhookDeliveries(hook.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p struct {
+		Event string
+		Data  PageData
+	}
+	if err := json.Unmarshal([]byte(deliveries[0].Payload), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Event != PageCreated || p.Data.User == nil || p.Data.User.Username != "alice" ||
+		p.Data.Page.URL != "https://wiki.example.com/aardvarks" {
+		t.Errorf("payload = %s", deliveries[0].Payload)
+	}
+}
+
+func TestSecretRequired(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := db.CreateWebhook("http://example.invalid/hook", "", []string{PageCreated}); err != database.ErrNoSecret {
+		t.Fatalf("CreateWebhook with no secret: err = %v, want ErrNoSecret", err)
+	}
+	hook, err := db.CreateWebhook("http://example.invalid/hook", "s3cret", []string{PageCreated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Secret = ""
+	if err := db.UpdateWebhook(hook); err != database.ErrNoSecret {
+		t.Fatalf("UpdateWebhook with no secret: err = %v, want ErrNoSecret", err)
+	}
+
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSecret()
+	if len(a) < MinSecretLength || a == b {
+		t.Errorf("NewSecret = %q, %q", a, b)
+	}
+}